@@ -0,0 +1,78 @@
+//go:build linux
+// +build linux
+
+// Mounts a Copy account as a local filesystem with FUSE:
+//
+//	copy-mount [-root photos] [-ro] [-cache ~/.cache/copy] MOUNTPOINT
+//
+// The filesystem is unmounted with Ctrl+C or fusermount -u MOUNTPOINT
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/slok/go-copy/copy"
+	"github.com/slok/go-copy/copyfuse"
+)
+
+var root = flag.String("root", "", "Remote directory to mount, the whole account by default")
+var readOnly = flag.Bool("ro", false, "Mount read-only")
+var cacheDir = flag.String("cache", "", "Directory of the content cache, a temporal directory by default")
+var metaTTL = flag.Duration("ttl", 30*time.Second, "Time the directory listings are cached")
+var profile = flag.String("profile", "", "Credentials profile of ~/.copy/credentials")
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] MOUNTPOINT\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	mountpoint := flag.Arg(0)
+
+	// Load the credentials from the env vars or ~/.copy/credentials
+	credentials := copy.NewChainCredentialsProvider(
+		copy.NewEnvCredentialsProvider(),
+		copy.NewFileCredentialsProvider("", *profile),
+	)
+
+	client, err := copy.New(
+		copy.WithCredentialsProvider(credentials),
+		copy.WithRateLimiter(copy.NewDefaultRateLimiter()),
+	)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not create the client: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Unmount on Ctrl+C
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		cancel()
+	}()
+
+	opts := &copyfuse.Options{
+		ReadOnly: *readOnly,
+		CacheDir: *cacheDir,
+		MetaTTL:  *metaTTL,
+	}
+
+	err = copyfuse.Mount(ctx, copy.NewFileService(client), *root, mountpoint, opts)
+	if err != nil && err != context.Canceled {
+		fmt.Fprintf(os.Stderr, "Could not mount %s: %v\n", mountpoint, err)
+		os.Exit(1)
+	}
+}
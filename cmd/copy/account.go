@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/slok/go-copy/copy"
+)
+
+func cmdWhoami(a *app, args []string) error {
+	if _, err := a.parseFlags(a.flagSet("whoami"), args, 0, 0); err != nil {
+		return err
+	}
+
+	user, err := a.users.GetContext(a.ctx)
+	if err != nil {
+		return err
+	}
+
+	return a.output(user, func(w io.Writer) {
+		fmt.Fprintf(w, "%s %s <%s>\n", user.FirstName, user.LastName, user.Email)
+		fmt.Fprintf(w, "Id: %s\n", user.Id)
+		if !user.CreatedTime.IsZero() {
+			fmt.Fprintf(w, "Created: %s\n", formatUnix(user.CreatedTime.Seconds()))
+		}
+	})
+}
+
+// The quota in JSON mode
+type quotaResult struct {
+	Used    int64   `json:"used"`
+	Quota   int64   `json:"quota"`
+	Saved   int64   `json:"saved"`
+	Percent float64 `json:"percent"`
+}
+
+func cmdQuota(a *app, args []string) error {
+	if _, err := a.parseFlags(a.flagSet("quota"), args, 0, 0); err != nil {
+		return err
+	}
+
+	user, err := a.users.GetContext(a.ctx)
+	if err != nil {
+		return err
+	}
+
+	result := quotaResult{
+		Used:  int64(user.Storage.Used),
+		Quota: int64(user.Storage.Quota),
+		Saved: int64(user.Storage.Saved),
+	}
+	if result.Quota > 0 {
+		result.Percent = float64(result.Used) * 100 / float64(result.Quota)
+	}
+
+	return a.output(result, func(w io.Writer) {
+		fmt.Fprintf(w, "%s of %s used (%.1f%%), %s free\n", copy.FormatBytes(result.Used),
+			copy.FormatBytes(result.Quota), result.Percent, copy.FormatBytes(result.Quota-result.Used))
+		if result.Saved > 0 {
+			fmt.Fprintf(w, "%s saved\n", copy.FormatBytes(result.Saved))
+		}
+	})
+}
+
+func cmdLink(a *app, args []string) error {
+	if len(args) == 0 {
+		args = []string{"ls"}
+	}
+
+	switch args[0] {
+	case "ls":
+		return a.linkLs(args[1:])
+	case "show":
+		return a.linkShow(args[1:])
+	case "create":
+		return a.linkCreate(args[1:])
+	case "rm":
+		return a.linkRm(args[1:])
+	case "-h", "-help", "--help":
+		a.flagSet("link").Usage()
+		return nil
+	}
+
+	return usagef("link", "unknown link command %s", args[0])
+}
+
+func (a *app) linkLs(args []string) error {
+	if _, err := a.parseFlags(a.flagSet("link"), args, 0, 0); err != nil {
+		return err
+	}
+
+	links, err := a.links.GetLinksContext(a.ctx)
+	if err != nil {
+		return err
+	}
+
+	return a.output(links, func(w io.Writer) {
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "TOKEN\tNAME\tPUBLIC\tURL")
+		for _, link := range links {
+			fmt.Fprintf(tw, "%s\t%s\t%t\t%s\n", link.Id, link.Name, link.Public, link.Url)
+		}
+		tw.Flush()
+	})
+}
+
+func (a *app) linkShow(args []string) error {
+	args, err := a.parseFlags(a.flagSet("link"), args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	link, err := a.links.GetLinkContext(a.ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	return a.output(link, func(w io.Writer) {
+		printLink(w, link)
+	})
+}
+
+func (a *app) linkCreate(args []string) error {
+	flags := a.flagSet("link")
+	public := flags.Bool("public", false, "Anyone with the URL can see the files")
+	name := flags.String("name", "", "Name of the link, the first path by default")
+	args, err := a.parseFlags(flags, args, 1, -1)
+	if err != nil {
+		return err
+	}
+
+	paths := make([]string, len(args))
+	for i, arg := range args {
+		paths[i] = "/" + a.remotePath(arg)
+	}
+
+	if *name == "" {
+		*name = strings.TrimPrefix(paths[0], "/")
+	}
+
+	link, err := a.links.CreateLinkContext(a.ctx, *name, paths, *public)
+	if err != nil {
+		return err
+	}
+
+	return a.output(link, func(w io.Writer) {
+		printLink(w, link)
+	})
+}
+
+func (a *app) linkRm(args []string) error {
+	args, err := a.parseFlags(a.flagSet("link"), args, 1, -1)
+	if err != nil {
+		return err
+	}
+
+	for _, token := range args {
+		if err := a.links.DeleteLinkContext(a.ctx, token); err != nil {
+			return err
+		}
+	}
+
+	return a.output(map[string][]string{"tokens": args}, func(w io.Writer) {})
+}
+
+func printLink(w io.Writer, link *copy.Link) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Token:\t%s\n", link.Id)
+	fmt.Fprintf(tw, "Name:\t%s\n", link.Name)
+	fmt.Fprintf(tw, "Public:\t%t\n", link.Public)
+	fmt.Fprintf(tw, "URL:\t%s\n", link.Url)
+	if link.Expired {
+		fmt.Fprintf(tw, "Expired:\ttrue\n")
+	}
+	for _, r := range link.Recipients {
+		fmt.Fprintf(tw, "Recipient:\t%s %s <%s>\n", r.FirstName, r.LastName, r.Email)
+	}
+	tw.Flush()
+}
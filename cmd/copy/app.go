@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/slok/go-copy/copy"
+)
+
+// app runs the commands with the services of a client
+type app struct {
+	ctx   context.Context
+	files *copy.FileService
+	users *copy.UserService
+	links *copy.LinkService
+
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	// Print the output as JSON
+	json bool
+
+	// Remote working directory of the shell, the relative paths are
+	// resolved from it ("" is the root)
+	cwd string
+
+	// Listings cached between the commands of the shell, nil outside
+	listings *listingCache
+
+	// Ctrl+C handler, nil in the tests
+	interrupts *interruptHandler
+}
+
+func newApp(ctx context.Context, client *copy.Client, stdin io.Reader, stdout, stderr io.Writer) *app {
+	return &app{
+		ctx:    ctx,
+		files:  copy.NewFileService(client),
+		users:  copy.NewUserService(client),
+		links:  copy.NewLinkService(client),
+		stdin:  stdin,
+		stdout: stdout,
+		stderr: stderr,
+	}
+}
+
+// A subcommand
+type command struct {
+	name string
+	args string
+	help string
+	run  func(a *app, args []string) error
+}
+
+var commands map[string]*command
+
+func init() {
+	commands = map[string]*command{}
+	for _, cmd := range []*command{
+		{"ls", "[-l] [-R] [PATH]", "Lists a directory", cmdLs},
+		{"stat", "PATH", "Shows the metadata of a file or directory", cmdStat},
+		{"get", "[-parallel N] [-progress] REMOTE [LOCAL|-]", "Downloads a file, resuming the partial downloads", cmdGet},
+		{"put", "[-f] [-progress] LOCAL|- REMOTE", "Uploads a file", cmdPut},
+		{"rm", "[-r] PATH...", "Deletes files (directories with -r)", cmdRm},
+		{"mv", "[-f] SRC DST", "Moves a file or directory (into DST if it is a directory)", cmdMv},
+		{"rename", "[-f] PATH NAME", "Renames a file or directory", cmdRename},
+		{"mkdir", "PATH...", "Creates directories", cmdMkdir},
+		{"revisions", "[-get TIME [-o LOCAL|-] | -restore TIME] PATH", "Lists the revisions of a file with their changes, downloads or restores one", cmdRevisions},
+		{"thumb", "[-size N] PATH [LOCAL|-]", "Downloads the thumbnail of a file", cmdThumb},
+		{"whoami", "", "Shows the user", cmdWhoami},
+		{"quota", "", "Shows the used storage", cmdQuota},
+		{"link", "[ls | show TOKEN | create [-public] [-name NAME] PATH... | rm TOKEN]", "Manages the shared links", cmdLink},
+		{"sync", "[-two-way] [-dry-run] [-no-delete] [-strategy S] LOCAL REMOTE", "Syncs a local directory with a Copy directory", cmdSync},
+		{"shell", "[-history FILE]", "Starts an interactive shell", cmdShell},
+		{"help", "[COMMAND]", "Shows the help of a command", cmdHelp},
+	} {
+		commands[cmd.name] = cmd
+	}
+}
+
+// Returned when the command is called with the wrong arguments
+type usageError struct {
+	cmd *command
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return fmt.Sprintf("Usage: copy %s %s", e.cmd.name, e.cmd.args)
+}
+
+func usagef(cmd string, format string, args ...interface{}) error {
+	return &usageError{cmd: commands[cmd], msg: fmt.Sprintf(format, args...)}
+}
+
+// Runs the command of the args
+func (a *app) exec(args []string) error {
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return usagef("help", "unknown command %s", args[0])
+	}
+	return cmd.run(a, args[1:])
+}
+
+// Returns the flags of the command, parse them with parseFlags
+func (a *app) flagSet(cmd string) *flag.FlagSet {
+	flags := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	flags.SetOutput(a.stderr)
+	flags.Usage = func() {
+		c := commands[cmd]
+		fmt.Fprintf(a.stderr, "Usage: copy %s %s\n\n%s\n", c.name, c.args, c.help)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// Parses the flags and checks the number of args (max < 0 is no limit)
+func (a *app) parseFlags(flags *flag.FlagSet, args []string, min, max int) ([]string, error) {
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil, err
+		}
+		return nil, &usageError{cmd: commands[flags.Name()]}
+	}
+
+	n := flags.NArg()
+	if n < min || (max >= 0 && n > max) {
+		return nil, usagef(flags.Name(), "wrong number of arguments")
+	}
+
+	return flags.Args(), nil
+}
+
+// Returns the remote path of an argument, the relative paths are relative
+// to the working directory
+func (a *app) remotePath(p string) string {
+	if !strings.HasPrefix(p, "/") {
+		p = a.cwd + "/" + p
+	}
+	return strings.Trim(path.Clean("/"+p), "/")
+}
+
+// Returns the children of the remote directory, in the shell from the
+// listings cache
+func (a *app) listDir(dir string) ([]copy.Meta, error) {
+	if a.listings != nil {
+		if children, ok := a.listings.get(dir); ok {
+			return children, nil
+		}
+	}
+
+	children := []copy.Meta{}
+	it := a.files.ListChildren(a.ctx, dir, nil)
+	for it.Next() {
+		children = append(children, *it.Meta())
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	if a.listings != nil {
+		a.listings.put(dir, children)
+	}
+	return children, nil
+}
+
+// Returns the metadata of the remote path, in the shell from the listing of
+// its parent
+func (a *app) lookup(remote string) (*copy.Meta, error) {
+	if remote == "" {
+		return &copy.Meta{Path: "/", Type: "root"}, nil
+	}
+
+	if a.listings == nil {
+		return a.files.GetMetaContext(a.ctx, remote)
+	}
+
+	children, err := a.listDir(strings.Trim(path.Dir("/"+remote), "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	name := path.Base(remote)
+	for i := range children {
+		if children[i].Name == name {
+			return &children[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s: %w", remote, copy.ErrNotFound)
+}
+
+// Checks if the remote path is a directory
+func (a *app) isDir(remote string) bool {
+	meta, err := a.lookup(remote)
+	return err == nil && meta.IsDir()
+}
+
+func printCommands(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "Commands:")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(tw, "  %s\t%s\n", name, commands[name].help)
+	}
+	tw.Flush()
+}
+
+func cmdHelp(a *app, args []string) error {
+	if len(args) == 0 {
+		printCommands(a.stdout)
+		return nil
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return usagef("help", "unknown command %s", args[0])
+	}
+
+	// The flags are printed by the command
+	return cmd.run(a, []string{"-h"})
+}
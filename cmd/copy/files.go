@@ -0,0 +1,376 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/slok/go-copy/copy"
+)
+
+func cmdLs(a *app, args []string) error {
+	flags := a.flagSet("ls")
+	long := flags.Bool("l", false, "Shows the type, size and modified time")
+	recursive := flags.Bool("R", false, "Lists the subdirectories too")
+	args, err := a.parseFlags(flags, args, 0, 1)
+	if err != nil {
+		return err
+	}
+
+	dir := a.remotePath(".")
+	if len(args) == 1 {
+		dir = a.remotePath(args[0])
+	}
+
+	// The paths are relative to the listed directory
+	entries := []copy.Meta{}
+
+	if *recursive {
+		root := "/" + dir
+		err = a.files.Walk(a.ctx, root, func(p string, meta *copy.Meta, err error) error {
+			if err != nil {
+				return err
+			}
+
+			rel := strings.TrimPrefix(strings.TrimPrefix(p, root), "/")
+			if rel == "" {
+				return nil
+			}
+
+			m := *meta
+			m.Children = nil
+			m.Path = rel
+			entries = append(entries, m)
+			return nil
+		})
+	} else {
+		var children []copy.Meta
+		children, err = a.listDir(dir)
+		for _, m := range children {
+			m.Path = m.Name
+			entries = append(entries, m)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return a.output(entries, func(w io.Writer) {
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, meta := range entries {
+			name := meta.Path
+			if meta.IsDir() {
+				name += "/"
+			}
+
+			if *long {
+				fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", meta.Type, meta.Size, formatUnix(meta.ModifiedTime.Seconds()), name)
+			} else {
+				fmt.Fprintln(tw, name)
+			}
+		}
+		tw.Flush()
+	})
+}
+
+func cmdStat(a *app, args []string) error {
+	args, err := a.parseFlags(a.flagSet("stat"), args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	meta, err := a.files.GetMetaContext(a.ctx, a.remotePath(args[0]))
+	if err != nil {
+		return err
+	}
+	meta.Children = nil
+
+	return a.output(meta, func(w io.Writer) {
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "Path:\t%s\n", meta.Path)
+		fmt.Fprintf(tw, "Type:\t%s\n", meta.Type)
+		if !meta.IsDir() {
+			fmt.Fprintf(tw, "Size:\t%d (%s)\n", meta.Size, copy.FormatBytes(int64(meta.Size)))
+			fmt.Fprintf(tw, "Mime type:\t%s\n", meta.MimeType)
+			fmt.Fprintf(tw, "Revision:\t%d\n", meta.Revision)
+		}
+		fmt.Fprintf(tw, "Modified:\t%s\n", formatUnix(meta.ModifiedTime.Seconds()))
+		if meta.Id != "" {
+			fmt.Fprintf(tw, "Id:\t%s\n", meta.Id)
+		}
+		if meta.Public || len(meta.Links) > 0 {
+			fmt.Fprintf(tw, "Links:\t%d\n", len(meta.Links))
+		}
+		tw.Flush()
+	})
+}
+
+// The result of the transfers in JSON mode
+type transferResult struct {
+	Remote string `json:"remote"`
+	Local  string `json:"local"`
+	Size   int64  `json:"size"`
+}
+
+func cmdGet(a *app, args []string) error {
+	flags := a.flagSet("get")
+	parallel := flags.Int("parallel", 0, "Downloads N segments of the file at the same time")
+	progress := flags.Bool("progress", false, "Shows a progress bar")
+	args, err := a.parseFlags(flags, args, 1, 2)
+	if err != nil {
+		return err
+	}
+
+	remote := a.remotePath(args[0])
+	local := path.Base(remote)
+	if len(args) == 2 {
+		local = args[1]
+	}
+
+	if local == "-" {
+		r, err := a.files.GetFileContext(a.ctx, remote)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+
+		_, err = io.Copy(a.stdout, r)
+		return err
+	}
+
+	if info, err := os.Stat(local); err == nil && info.IsDir() {
+		local = filepath.Join(local, path.Base(remote))
+	}
+
+	var progressFunc copy.ProgressFunc
+	if *progress {
+		progressFunc = copy.NewProgressBar(a.stderr, path.Base(remote)).Update
+	}
+
+	if *parallel > 1 {
+		err = a.files.DownloadParallel(a.ctx, remote, local, &copy.ParallelDownloadOptions{
+			Concurrency: *parallel,
+			Progress:    progressFunc,
+		})
+	} else {
+		err = a.files.DownloadToFile(a.ctx, remote, local, &copy.DownloadOptions{Progress: progressFunc})
+	}
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(local)
+	if err != nil {
+		return err
+	}
+
+	result := transferResult{Remote: remote, Local: local, Size: info.Size()}
+	return a.output(result, func(w io.Writer) {
+		fmt.Fprintf(w, "%s -> %s (%s)\n", remote, local, copy.FormatBytes(result.Size))
+	})
+}
+
+func cmdPut(a *app, args []string) error {
+	flags := a.flagSet("put")
+	force := flags.Bool("f", false, "Overwrites the remote file if it exists")
+	progress := flags.Bool("progress", false, "Shows a progress bar")
+	args, err := a.parseFlags(flags, args, 2, 2)
+	if err != nil {
+		return err
+	}
+
+	local := args[0]
+	remote := a.remotePath(args[1])
+	if strings.HasSuffix(args[1], "/") || a.isDir(remote) {
+		if local == "-" {
+			return usagef("put", "the remote path of the standard input can't be a directory")
+		}
+		remote = path.Join(remote, filepath.Base(local))
+	}
+
+	opts := &copy.UploadOptions{Overwrite: *force}
+	result := transferResult{Remote: remote, Local: local, Size: -1}
+
+	if local == "-" {
+		err = a.files.UploadReaderContext(a.ctx, a.stdin, -1, remote, opts)
+	} else {
+		info, statErr := os.Stat(local)
+		if statErr != nil {
+			return statErr
+		}
+		if info.IsDir() {
+			return fmt.Errorf("%s is a directory, use sync for uploading directories", local)
+		}
+		result.Size = info.Size()
+
+		if *progress {
+			opts.Progress = copy.NewProgressBar(a.stderr, filepath.Base(local)).Update
+		}
+		err = a.files.UploadFileWithOptions(a.ctx, local, remote, opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	return a.output(result, func(w io.Writer) {
+		if result.Size >= 0 {
+			fmt.Fprintf(w, "%s -> %s (%s)\n", local, remote, copy.FormatBytes(result.Size))
+		} else {
+			fmt.Fprintf(w, "%s -> %s\n", local, remote)
+		}
+	})
+}
+
+// The result of the commands that change paths in JSON mode
+type pathsResult struct {
+	Paths []string `json:"paths"`
+}
+
+func cmdRm(a *app, args []string) error {
+	flags := a.flagSet("rm")
+	recursive := flags.Bool("r", false, "Deletes the directories with their content")
+	args, err := a.parseFlags(flags, args, 1, -1)
+	if err != nil {
+		return err
+	}
+
+	result := pathsResult{Paths: []string{}}
+	for _, arg := range args {
+		remote := a.remotePath(arg)
+		if remote == "" {
+			return errors.New("Refusing to delete the root directory")
+		}
+
+		meta, err := a.lookup(remote)
+		if err != nil {
+			return err
+		}
+		if meta.IsDir() && !*recursive {
+			return fmt.Errorf("%s is a directory, use -r", remote)
+		}
+
+		if err := a.files.DeleteFileContext(a.ctx, remote); err != nil {
+			return err
+		}
+		result.Paths = append(result.Paths, remote)
+	}
+
+	return a.output(result, func(w io.Writer) {})
+}
+
+// The result of the moves and renames in JSON mode
+type moveResult struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func cmdMv(a *app, args []string) error {
+	flags := a.flagSet("mv")
+	force := flags.Bool("f", false, "Overwrites the destination if it exists")
+	args, err := a.parseFlags(flags, args, 2, 2)
+	if err != nil {
+		return err
+	}
+
+	src := a.remotePath(args[0])
+	dst := a.remotePath(args[1])
+	if a.isDir(dst) {
+		dst = path.Join(dst, path.Base(src))
+	}
+
+	if err := a.files.MoveFileContext(a.ctx, src, dst, *force); err != nil {
+		return err
+	}
+
+	return a.output(moveResult{From: src, To: dst}, func(w io.Writer) {})
+}
+
+func cmdRename(a *app, args []string) error {
+	flags := a.flagSet("rename")
+	force := flags.Bool("f", false, "Overwrites the destination if it exists")
+	args, err := a.parseFlags(flags, args, 2, 2)
+	if err != nil {
+		return err
+	}
+
+	remote := a.remotePath(args[0])
+	name := args[1]
+	if name == "" || strings.Contains(name, "/") {
+		return usagef("rename", "wrong name %q, use mv for moving", name)
+	}
+
+	if err := a.files.RenameFileContext(a.ctx, remote, name, *force); err != nil {
+		return err
+	}
+
+	return a.output(moveResult{From: remote, To: path.Join(path.Dir(remote), name)}, func(w io.Writer) {})
+}
+
+func cmdMkdir(a *app, args []string) error {
+	args, err := a.parseFlags(a.flagSet("mkdir"), args, 1, -1)
+	if err != nil {
+		return err
+	}
+
+	result := pathsResult{Paths: []string{}}
+	for _, arg := range args {
+		remote := a.remotePath(arg)
+		if err := a.files.CreateDirectoryContext(a.ctx, remote, false); err != nil {
+			return err
+		}
+		result.Paths = append(result.Paths, remote)
+	}
+
+	return a.output(result, func(w io.Writer) {})
+}
+
+func cmdThumb(a *app, args []string) error {
+	flags := a.flagSet("thumb")
+	size := flags.Int("size", 128, "Size of the thumbnail: 32, 64, 128, 256, 512 or 1024")
+	args, err := a.parseFlags(flags, args, 1, 2)
+	if err != nil {
+		return err
+	}
+
+	remote := a.remotePath(args[0])
+	local := fmt.Sprintf("thumb_%d_%s", *size, path.Base(remote))
+	if len(args) == 2 {
+		local = args[1]
+	}
+
+	r, err := a.files.GetThumbnailContext(a.ctx, remote, *size)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if local == "-" {
+		_, err = io.Copy(a.stdout, r)
+		return err
+	}
+
+	f, err := os.Create(local)
+	if err != nil {
+		return err
+	}
+
+	n, err := io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(local)
+		return err
+	}
+
+	result := transferResult{Remote: remote, Local: local, Size: n}
+	return a.output(result, func(w io.Writer) {
+		fmt.Fprintf(w, "%s -> %s (%s)\n", remote, local, copy.FormatBytes(n))
+	})
+}
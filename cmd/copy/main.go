@@ -0,0 +1,197 @@
+// Command copy is a command line client for Copy:
+//
+//	copy [-profile NAME] [-json] COMMAND [ARGS]
+//
+// Run "copy help" for the list of commands. The credentials are loaded from
+// the APP_TOKEN, APP_SECRET, ACCESS_TOKEN and ACCESS_SECRET env vars or
+// from a profile of ~/.copy/credentials.
+//
+// The exit codes are:
+//
+//	0    success
+//	1    any other error
+//	2    wrong arguments
+//	3    not found
+//	4    unauthorized
+//	5    conflict (already exists)
+//	6    quota exceeded
+//	7    network error
+//	130  interrupted
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/slok/go-copy/copy"
+)
+
+// Exit codes
+const (
+	exitOK           = 0
+	exitError        = 1
+	exitUsage        = 2
+	exitNotFound     = 3
+	exitUnauthorized = 4
+	exitConflict     = 5
+	exitQuota        = 6
+	exitNetwork      = 7
+	exitInterrupted  = 130
+)
+
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupts := &interruptHandler{fn: cancel}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for sig := range signals {
+			if sig == os.Interrupt {
+				interrupts.interrupt()
+			} else {
+				cancel()
+			}
+		}
+	}()
+
+	os.Exit(run(ctx, interrupts, os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// Calls the Ctrl+C handler, by default it cancels everything. The shell sets
+// its own handler for cancelling only the running command
+type interruptHandler struct {
+	mu sync.Mutex
+	fn func()
+}
+
+func (h *interruptHandler) interrupt() {
+	h.mu.Lock()
+	fn := h.fn
+	h.mu.Unlock()
+	fn()
+}
+
+// Sets the handler, returns a function that restores the previous one
+func (h *interruptHandler) set(fn func()) (restore func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	previous := h.fn
+	h.fn = fn
+	return func() {
+		h.mu.Lock()
+		h.fn = previous
+		h.mu.Unlock()
+	}
+}
+
+// Runs the command line and returns the exit code
+func run(ctx context.Context, interrupts *interruptHandler, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("copy", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	profile := flags.String("profile", os.Getenv("COPY_PROFILE"), "Credentials profile, the env vars are ignored when set")
+	credentialsFile := flags.String("credentials", "", "Credentials file, ~/.copy/credentials by default")
+	jsonOutput := flags.Bool("json", false, "Print the output as JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: copy [options] COMMAND [ARGS]")
+		flags.PrintDefaults()
+		fmt.Fprintln(stderr)
+		printCommands(stderr)
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	// The env vars win unless a profile is asked
+	fileProvider := copy.NewFileCredentialsProvider(*credentialsFile, *profile)
+	var credentials copy.CredentialsProvider = copy.NewChainCredentialsProvider(
+		copy.NewEnvCredentialsProvider(),
+		fileProvider,
+	)
+	if *profile != "" {
+		credentials = fileProvider
+	}
+
+	client, err := copy.New(
+		copy.WithCredentialsProvider(credentials),
+		copy.WithRateLimiter(copy.NewDefaultRateLimiter()),
+	)
+	if err != nil {
+		fmt.Fprintf(stderr, "copy: Could not create the client: %v\n", err)
+		return exitError
+	}
+
+	a := newApp(ctx, client, stdin, stdout, stderr)
+	a.json = *jsonOutput
+	a.interrupts = interrupts
+
+	return a.exitCode(a.exec(flags.Args()))
+}
+
+// Returns the exit code of the error, printing it
+func (a *app) exitCode(err error) int {
+	if err == nil || err == flag.ErrHelp {
+		return exitOK
+	}
+
+	code := exitCodeOf(err)
+
+	var usage *usageError
+	switch {
+	case errors.As(err, &usage):
+		if usage.msg != "" {
+			fmt.Fprintf(a.stderr, "copy %s: %s\n", usage.cmd.name, usage.msg)
+		}
+		fmt.Fprintf(a.stderr, "Usage: copy %s %s\n", usage.cmd.name, usage.cmd.args)
+	case a.json:
+		a.printJSONError(err, code)
+	default:
+		fmt.Fprintf(a.stderr, "copy: %v\n", err)
+	}
+
+	return code
+}
+
+// Maps the errors to the exit codes
+func exitCodeOf(err error) int {
+	var usage *usageError
+	var apiErr *copy.APIError
+
+	switch {
+	case err == nil, err == flag.ErrHelp:
+		return exitOK
+	case errors.As(err, &usage):
+		return exitUsage
+	case errors.Is(err, context.Canceled):
+		return exitInterrupted
+	case copy.IsNotFound(err):
+		return exitNotFound
+	case copy.IsUnauthorized(err):
+		return exitUnauthorized
+	case copy.IsConflict(err):
+		return exitConflict
+	case copy.IsQuotaExceeded(err):
+		return exitQuota
+	case errors.As(err, &apiErr) && apiErr.StatusCode == 0:
+		return exitNetwork
+	}
+
+	return exitError
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/slok/go-copy/copy"
+)
+
+var (
+	mux     *http.ServeMux
+	server  *httptest.Server
+	stdout  *bytes.Buffer
+	stderr  *bytes.Buffer
+	testApp *app
+)
+
+func setup(t *testing.T) {
+	mux = http.NewServeMux()
+	server = httptest.NewServer(mux)
+
+	client, err := copy.New(
+		copy.WithBaseURL(server.URL),
+		copy.WithCredentials("a", "b", "c", "d"),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	stdout = &bytes.Buffer{}
+	stderr = &bytes.Buffer{}
+	testApp = newApp(context.Background(), client, strings.NewReader(""), stdout, stderr)
+}
+
+func tearDown() {
+	server.Close()
+}
+
+// Runs the command line with the test app and returns the exit code
+func runTest(args ...string) int {
+	return testApp.exitCode(testApp.exec(args))
+}
+
+func TestExitCodes(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, exitOK},
+		{errors.New("other"), exitError},
+		{usagef("ls", "wrong"), exitUsage},
+		{context.Canceled, exitInterrupted},
+		{&copy.APIError{StatusCode: http.StatusNotFound}, exitNotFound},
+		{&copy.APIError{StatusCode: http.StatusUnauthorized}, exitUnauthorized},
+		{&copy.APIError{StatusCode: http.StatusConflict}, exitConflict},
+		{&copy.APIError{StatusCode: http.StatusInsufficientStorage}, exitQuota},
+		{&copy.APIError{Err: errors.New("connection refused")}, exitNetwork},
+		{&copy.APIError{StatusCode: http.StatusInternalServerError}, exitError},
+		{fmt.Errorf("Could not stat: %w", &copy.APIError{StatusCode: http.StatusNotFound}), exitNotFound},
+	}
+
+	for _, test := range tests {
+		if got := exitCodeOf(test.err); got != test.want {
+			t.Errorf("Wrong exit code of %v: got %d, want %d", test.err, got, test.want)
+		}
+	}
+}
+
+func TestUsage(t *testing.T) {
+	setup(t)
+	defer tearDown()
+
+	if code := runTest("stat"); code != exitUsage {
+		t.Errorf("Wrong exit code: %d", code)
+	}
+	if !strings.Contains(stderr.String(), "Usage: copy stat PATH") {
+		t.Errorf("Wrong usage: %q", stderr.String())
+	}
+
+	if code := runTest("unknown"); code != exitUsage {
+		t.Errorf("Wrong exit code of an unknown command: %d", code)
+	}
+
+	if code := runTest("help"); code != exitOK || !strings.Contains(stdout.String(), "revisions") {
+		t.Errorf("Wrong help: %d %q", code, stdout.String())
+	}
+}
+
+func TestLs(t *testing.T) {
+	setup(t)
+	defer tearDown()
+
+	mux.HandleFunc("/meta/copy/photos", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"path":"/photos","type":"dir","children_count":2,"children":[
+			{"name":"a.jpg","path":"/photos/a.jpg","type":"file","size":2048,"modified_time":1400000000},
+			{"name":"trips","path":"/photos/trips","type":"dir"}
+		]}`)
+	})
+
+	if code := runTest("ls", "photos"); code != exitOK {
+		t.Fatalf("Wrong exit code: %d %s", code, stderr.String())
+	}
+	if got := stdout.String(); got != "a.jpg\ntrips/\n" {
+		t.Errorf("Wrong ls output: %q", got)
+	}
+
+	stdout.Reset()
+	testApp.json = true
+	if code := runTest("ls", "/photos/"); code != exitOK {
+		t.Fatalf("Wrong exit code: %d %s", code, stderr.String())
+	}
+
+	entries := []copy.Meta{}
+	if err := json.Unmarshal(stdout.Bytes(), &entries); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(entries) != 2 || entries[0].Path != "a.jpg" || entries[0].Size != 2048 || !entries[1].IsDir() {
+		t.Errorf("Wrong ls JSON: %+v", entries)
+	}
+}
+
+func TestStatNotFound(t *testing.T) {
+	setup(t)
+	defer tearDown()
+
+	mux.HandleFunc("/meta/copy/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":1021,"message":"Cannot find object"}`)
+	})
+
+	testApp.json = true
+	if code := runTest("stat", "missing"); code != exitNotFound {
+		t.Errorf("Wrong exit code: %d", code)
+	}
+
+	jsonErr := jsonError{}
+	if err := json.Unmarshal(stderr.Bytes(), &jsonErr); err != nil {
+		t.Fatal(err.Error())
+	}
+	if jsonErr.ExitCode != exitNotFound || jsonErr.StatusCode != http.StatusNotFound || jsonErr.Code != 1021 {
+		t.Errorf("Wrong JSON error: %+v", jsonErr)
+	}
+}
+
+func TestGetStdout(t *testing.T) {
+	setup(t)
+	defer tearDown()
+
+	mux.HandleFunc("/files/docs/a.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello")
+	})
+
+	if code := runTest("get", "docs/a.txt", "-"); code != exitOK {
+		t.Fatalf("Wrong exit code: %d %s", code, stderr.String())
+	}
+	if stdout.String() != "hello" {
+		t.Errorf("Wrong content: %q", stdout.String())
+	}
+}
+
+func TestPutIntoDirectory(t *testing.T) {
+	setup(t)
+	defer tearDown()
+
+	local, err := ioutil.TempFile("", "put-test")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer local.Close()
+	local.WriteString("content")
+
+	mux.HandleFunc("/meta/copy/docs", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"path":"/docs","type":"dir"}`)
+	})
+
+	uploaded := ""
+	mux.HandleFunc("/files/docs", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		data, _ := ioutil.ReadAll(file)
+		uploaded = header.Filename + ":" + string(data)
+		fmt.Fprint(w, `{}`)
+	})
+
+	testApp.json = true
+	if code := runTest("put", local.Name(), "docs"); code != exitOK {
+		t.Fatalf("Wrong exit code: %d %s", code, stderr.String())
+	}
+
+	name := local.Name()[strings.LastIndex(local.Name(), "/")+1:]
+	if uploaded != name+":content" {
+		t.Errorf("Wrong upload: %q", uploaded)
+	}
+
+	result := transferResult{}
+	json.Unmarshal(stdout.Bytes(), &result)
+	if result.Remote != "docs/"+name || result.Size != 7 {
+		t.Errorf("Wrong put result: %+v", result)
+	}
+}
+
+func TestPutFailed(t *testing.T) {
+	setup(t)
+	defer tearDown()
+
+	local, err := ioutil.TempFile("", "put-test")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer local.Close()
+	local.WriteString("content")
+
+	mux.HandleFunc("/files/docs", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInsufficientStorage)
+		fmt.Fprint(w, `{"error":1024,"message":"Over quota"}`)
+	})
+
+	if code := runTest("put", local.Name(), "docs/a.txt"); code != exitQuota {
+		t.Errorf("Wrong exit code: %d", code)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("Shouldn't print the result: %q", stdout.String())
+	}
+}
+
+func TestRmDirectory(t *testing.T) {
+	setup(t)
+	defer tearDown()
+
+	deleted := false
+	mux.HandleFunc("/meta/copy/dir", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"path":"/dir","type":"dir"}`)
+	})
+	mux.HandleFunc("/files/dir", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "DELETE")
+		deleted = true
+		fmt.Fprint(w, `{}`)
+	})
+
+	if code := runTest("rm", "dir"); code != exitError || deleted {
+		t.Errorf("Directories should not be deleted without -r: %d", code)
+	}
+	if code := runTest("rm", "-r", "dir"); code != exitOK || !deleted {
+		t.Errorf("Directory should be deleted: %d %s", code, stderr.String())
+	}
+}
+
+func TestQuota(t *testing.T) {
+	setup(t)
+	defer tearDown()
+
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"1","storage":{"used":1073741824,"quota":4294967296,"saved":0}}`)
+	})
+
+	if code := runTest("quota"); code != exitOK {
+		t.Fatalf("Wrong exit code: %d %s", code, stderr.String())
+	}
+	if got := stdout.String(); got != "1.0 GB of 4.0 GB used (25.0%), 3.0 GB free\n" {
+		t.Errorf("Wrong quota: %q", got)
+	}
+}
+
+// From go-github (https://github.com/google/go-github)
+func testMethod(t *testing.T, r *http.Request, want string) {
+	if want != r.Method {
+		t.Errorf("Request method = %v, want %v", r.Method, want)
+	}
+}
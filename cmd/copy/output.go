@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/slok/go-copy/copy"
+)
+
+// Prints the value as JSON in JSON mode, otherwise prints the text
+func (a *app) output(v interface{}, text func(w io.Writer)) error {
+	if a.json {
+		enc := json.NewEncoder(a.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	text(a.stdout)
+	return nil
+}
+
+// The errors in JSON mode
+type jsonError struct {
+	Error    string `json:"error"`
+	ExitCode int    `json:"exit_code"`
+
+	// Only the API errors
+	StatusCode int    `json:"status_code,omitempty"`
+	Code       int    `json:"code,omitempty"`
+	Message    string `json:"message,omitempty"`
+}
+
+func (a *app) printJSONError(err error, code int) {
+	jsonErr := jsonError{Error: err.Error(), ExitCode: code}
+
+	var apiErr *copy.APIError
+	if errors.As(err, &apiErr) {
+		jsonErr.StatusCode = apiErr.StatusCode
+		jsonErr.Code = apiErr.Code
+		jsonErr.Message = apiErr.Message
+	}
+
+	enc := json.NewEncoder(a.stderr)
+	enc.SetIndent("", "  ")
+	enc.Encode(jsonErr)
+}
+
+// Returns the unix time as a local date, empty if not set
+func formatUnix(seconds int64) string {
+	if seconds <= 0 {
+		return ""
+	}
+	return time.Unix(seconds, 0).Format("2006-01-02 15:04:05")
+}
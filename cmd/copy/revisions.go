@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/slok/go-copy/copy"
+)
+
+// A revision with the size change from the previous one in the revisions
+// view
+type revisionEntry struct {
+	copy.Revision
+
+	// Unix time of the revision, it identifies the revision for -get and
+	// -restore
+	Time int `json:"time"`
+
+	SizeChange int `json:"size_change"`
+}
+
+func cmdRevisions(a *app, args []string) error {
+	flags := a.flagSet("revisions")
+	get := flags.Int("get", 0, "Downloads the revision of TIME")
+	out := flags.String("o", "", "File of the downloaded revision (- for the standard output), PATH name by default")
+	restore := flags.Int("restore", 0, "Makes the revision of TIME the current one")
+	args, err := a.parseFlags(flags, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	remote := a.remotePath(args[0])
+
+	switch {
+	case *get != 0 && *restore != 0:
+		return usagef("revisions", "-get and -restore can't be used together")
+	case *get != 0:
+		return a.getRevision(remote, *get, *out)
+	case *restore != 0:
+		if err := a.files.RestoreRevision(a.ctx, remote, *restore); err != nil {
+			return err
+		}
+		return a.output(map[string]interface{}{"path": remote, "restored": *restore}, func(w io.Writer) {
+			fmt.Fprintf(w, "%s restored to the revision of %s\n", remote, formatUnix(int64(*restore)))
+		})
+	}
+
+	revisions, err := a.files.ListRevisionsMetaContext(a.ctx, remote)
+	if err != nil {
+		return err
+	}
+
+	entries := revisionEntries(revisions)
+
+	return a.output(entries, func(w io.Writer) {
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "TIME\tMODIFIED\tSIZE\tCHANGE\tCREATOR\t")
+		for i, e := range entries {
+			change := "new"
+			if i < len(entries)-1 {
+				change = formatSizeChange(e.SizeChange)
+			}
+
+			marks := []string{}
+			if e.Latest {
+				marks = append(marks, "latest")
+			}
+			if e.Conflict != 0 {
+				marks = append(marks, "conflict")
+			}
+
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", e.Time, formatUnix(int64(e.Time)),
+				copy.FormatBytes(int64(e.Size)), change, creatorName(e.Creator), strings.Join(marks, ", "))
+		}
+		tw.Flush()
+	})
+}
+
+// Returns the revisions from the newest with the size changes from the
+// previous (older) revision
+func revisionEntries(revisions []copy.Revision) []revisionEntry {
+	entries := make([]revisionEntry, len(revisions))
+	for i, rev := range revisions {
+		entries[i].Revision = rev
+		entries[i].Time = int(rev.ModifiedTime.Seconds())
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time > entries[j].Time
+	})
+
+	for i := 0; i < len(entries)-1; i++ {
+		entries[i].SizeChange = int(entries[i].Size - entries[i+1].Size)
+	}
+
+	return entries
+}
+
+func (a *app) getRevision(remote string, revision int, local string) error {
+	r, err := a.files.GetFileRevision(a.ctx, remote, revision)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if local == "-" {
+		_, err = io.Copy(a.stdout, r)
+		return err
+	}
+
+	if local == "" {
+		local = path.Base(remote)
+	}
+
+	f, err := os.Create(local)
+	if err != nil {
+		return err
+	}
+
+	n, err := io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(local)
+		return err
+	}
+
+	result := transferResult{Remote: remote, Local: local, Size: n}
+	return a.output(result, func(w io.Writer) {
+		fmt.Fprintf(w, "%s (%s) -> %s (%s)\n", remote, formatUnix(int64(revision)), local, copy.FormatBytes(n))
+	})
+}
+
+// Returns the name of the creator of a revision, the email if it has no name
+func creatorName(c copy.Creator) string {
+	name := strings.TrimSpace(c.FirstName + " " + c.LastName)
+	if name == "" {
+		return c.Email
+	}
+	return name
+}
+
+func formatSizeChange(n int) string {
+	switch {
+	case n > 0:
+		return "+" + copy.FormatBytes(int64(n))
+	case n < 0:
+		return "-" + copy.FormatBytes(int64(-n))
+	}
+	return "="
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+const revisionsResponse = `{"revisions":[
+	{"revision_id":"4800","modified_time":"1365532000","size":100,"creator":{"first_name":"Jane","last_name":"Doe"}},
+	{"revision_id":"5000","modified_time":"1365532651","size":90,"latest":true,"creator":{"email":"bob@example.com"}},
+	{"revision_id":"4900","modified_time":"1365532300","size":2148,"creator":{"first_name":"Jane","last_name":"Doe"}}
+]}`
+
+func TestRevisionsView(t *testing.T) {
+	setup(t)
+	defer tearDown()
+
+	mux.HandleFunc("/meta/copy/docs/notes.txt/@activity", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, revisionsResponse)
+	})
+
+	if code := runTest("revisions", "docs/notes.txt"); code != exitOK {
+		t.Fatalf("Wrong exit code: %d %s", code, stderr.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Wrong revisions view: %q", stdout.String())
+	}
+
+	// From the newest with the changes from the previous revision
+	want := []struct {
+		time                  int64
+		size, change, creator string
+	}{
+		{1365532651, "90 B", "-2.0 KB", "bob@example.com"},
+		{1365532300, "2.1 KB", "+2.0 KB", "Jane Doe"},
+		{1365532000, "100 B", "new", "Jane Doe"},
+	}
+	for i, w := range want {
+		line := lines[i+1]
+		modified := time.Unix(w.time, 0).Format("2006-01-02 15:04:05")
+		for _, field := range []string{fmt.Sprint(w.time), modified, w.size, w.change, w.creator} {
+			if !strings.Contains(line, field) {
+				t.Errorf("Revision line %q should contain %q", line, field)
+			}
+		}
+	}
+	if !strings.Contains(lines[1], "latest") {
+		t.Errorf("Newest revision should be the latest: %q", lines[1])
+	}
+
+	stdout.Reset()
+	testApp.json = true
+	runTest("revisions", "docs/notes.txt")
+
+	entries := []revisionEntry{}
+	if err := json.Unmarshal(stdout.Bytes(), &entries); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(entries) != 3 || entries[0].Time != 1365532651 || entries[0].SizeChange != -2058 || entries[0].RevisionId != "5000" {
+		t.Errorf("Wrong revisions JSON: %+v", entries)
+	}
+}
+
+func TestRevisionsGetAndRestore(t *testing.T) {
+	setup(t)
+	defer tearDown()
+
+	mux.HandleFunc("/files/docs/notes.txt/@activity/@time:1365532000", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "old content")
+	})
+
+	restored := ""
+	mux.HandleFunc("/files/docs/notes.txt", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "PUT")
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		buf := make([]byte, 100)
+		n, _ := file.Read(buf)
+		restored = string(buf[:n])
+		fmt.Fprint(w, `{}`)
+	})
+
+	if code := runTest("revisions", "-get", "1365532000", "-o", "-", "docs/notes.txt"); code != exitOK {
+		t.Fatalf("Wrong exit code: %d %s", code, stderr.String())
+	}
+	if stdout.String() != "old content" {
+		t.Errorf("Wrong revision content: %q", stdout.String())
+	}
+
+	if code := runTest("revisions", "-restore", "1365532000", "docs/notes.txt"); code != exitOK {
+		t.Fatalf("Wrong exit code: %d %s", code, stderr.String())
+	}
+	if restored != "old content" {
+		t.Errorf("Wrong restored content: %q", restored)
+	}
+
+	if code := runTest("revisions", "-restore", "1", "-get", "1", "docs/notes.txt"); code != exitUsage {
+		t.Errorf("-get and -restore together should be a usage error: %d", code)
+	}
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/peterh/liner"
+	"github.com/slok/go-copy/copy"
+)
+
+// Time the listings are cached in the shell
+const shellListingTTL = time.Minute
+
+// The commands that don't change the remote files, the rest clear the
+// listings cache of the shell
+var readOnlyCommands = map[string]bool{
+	"ls": true, "stat": true, "get": true, "thumb": true,
+	"whoami": true, "quota": true, "help": true, "cd": true, "pwd": true,
+	"history": true,
+}
+
+// The commands that only exist in the shell
+var shellCommands = map[string]*command{
+	"cd":      {name: "cd", args: "[PATH | -]", help: "Changes the working directory (- is the previous one)"},
+	"pwd":     {name: "pwd", help: "Shows the working directory"},
+	"history": {name: "history", help: "Shows the commands of the session"},
+	"exit":    {name: "exit", help: "Exits the shell"},
+}
+
+// Caches the directory listings of the shell for a time
+type listingCache struct {
+	ttl  time.Duration
+	dirs map[string]cachedListing
+}
+
+type cachedListing struct {
+	children []copy.Meta
+	fetched  time.Time
+}
+
+func newListingCache(ttl time.Duration) *listingCache {
+	return &listingCache{ttl: ttl, dirs: map[string]cachedListing{}}
+}
+
+func (c *listingCache) get(dir string) ([]copy.Meta, bool) {
+	listing, ok := c.dirs[dir]
+	if !ok || time.Since(listing.fetched) >= c.ttl {
+		return nil, false
+	}
+	return listing.children, true
+}
+
+func (c *listingCache) put(dir string, children []copy.Meta) {
+	c.dirs[dir] = cachedListing{children: children, fetched: time.Now()}
+}
+
+func (c *listingCache) clear() {
+	c.dirs = map[string]cachedListing{}
+}
+
+// shell runs the command lines of the interactive shell
+type shell struct {
+	a *app
+
+	// The context of the shell, every command gets its own context that
+	// Ctrl+C cancels
+	root context.Context
+
+	// Previous working directory for "cd -"
+	previous string
+
+	// The command lines of the session
+	history []string
+}
+
+func newShell(a *app) *shell {
+	a.listings = newListingCache(shellListingTTL)
+	return &shell{a: a, root: a.ctx}
+}
+
+// Returns the default history file: ~/.copy/shell_history
+func defaultHistoryFile() string {
+	credentials := copy.DefaultCredentialsFile()
+	if credentials == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(credentials), "shell_history")
+}
+
+func cmdShell(a *app, args []string) error {
+	flags := a.flagSet("shell")
+	historyPath := flags.String("history", defaultHistoryFile(), "File of the command history, empty for not saving it")
+	if _, err := a.parseFlags(flags, args, 0, 0); err != nil {
+		return err
+	}
+
+	if a.listings != nil {
+		return errors.New("Already in the shell")
+	}
+
+	sh := newShell(a)
+	defer func() { a.listings = nil }()
+
+	line := liner.NewLiner()
+	defer line.Close()
+	line.SetCtrlCAborts(true)
+	line.SetWordCompleter(sh.complete)
+
+	if *historyPath != "" {
+		if f, err := os.Open(*historyPath); err == nil {
+			line.ReadHistory(f)
+			f.Close()
+		}
+		defer saveHistory(line, *historyPath)
+	}
+
+	for sh.root.Err() == nil {
+		input, err := line.Prompt(sh.prompt())
+		switch {
+		case err == liner.ErrPromptAborted:
+			continue
+		case err == io.EOF:
+			fmt.Fprintln(a.stdout)
+			return nil
+		case err != nil:
+			return err
+		}
+
+		if strings.TrimSpace(input) == "" {
+			continue
+		}
+		line.AppendHistory(input)
+
+		if sh.execLine(input) {
+			return nil
+		}
+	}
+
+	return sh.root.Err()
+}
+
+func saveHistory(line *liner.State, historyPath string) {
+	if err := os.MkdirAll(filepath.Dir(historyPath), 0700); err != nil {
+		return
+	}
+
+	f, err := os.OpenFile(historyPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	line.WriteHistory(f)
+}
+
+func (sh *shell) prompt() string {
+	return fmt.Sprintf("copy:/%s> ", sh.a.cwd)
+}
+
+// Runs a command line printing the errors, returns true if the shell must
+// exit
+func (sh *shell) execLine(input string) bool {
+	args, err := splitArgs(input)
+	if err != nil {
+		fmt.Fprintf(sh.a.stderr, "copy: %v\n", err)
+		return false
+	}
+	if len(args) == 0 {
+		return false
+	}
+
+	sh.history = append(sh.history, input)
+
+	if args[0] == "exit" || args[0] == "quit" {
+		return true
+	}
+
+	ctx, cancel := context.WithCancel(sh.root)
+	defer cancel()
+	if sh.a.interrupts != nil {
+		defer sh.a.interrupts.set(cancel)()
+	}
+
+	sh.a.ctx = ctx
+	err = sh.exec(args)
+	sh.a.ctx = sh.root
+
+	if !readOnlyCommands[args[0]] {
+		sh.a.listings.clear()
+	}
+
+	sh.a.exitCode(err)
+	return false
+}
+
+func (sh *shell) exec(args []string) error {
+	switch args[0] {
+	case "cd":
+		return sh.cd(args[1:])
+	case "pwd":
+		fmt.Fprintf(sh.a.stdout, "/%s\n", sh.a.cwd)
+		return nil
+	case "history":
+		for i, input := range sh.history {
+			fmt.Fprintf(sh.a.stdout, "%4d  %s\n", i+1, input)
+		}
+		return nil
+	case "help":
+		if len(args) == 1 {
+			printCommands(sh.a.stdout)
+			fmt.Fprintln(sh.a.stdout, "\nShell commands:")
+			for _, name := range sortedNames(shellCommands) {
+				fmt.Fprintf(sh.a.stdout, "  %-9s %s\n", name, shellCommands[name].help)
+			}
+			return nil
+		}
+
+		// Like the help of the commands
+		if cmd, ok := shellCommands[args[1]]; ok {
+			fmt.Fprintf(sh.a.stderr, "Usage: copy %s %s\n\n%s\n", cmd.name, cmd.args, cmd.help)
+			return nil
+		}
+	}
+
+	return sh.a.exec(args)
+}
+
+func (sh *shell) cd(args []string) error {
+	if len(args) > 1 {
+		return &usageError{cmd: shellCommands["cd"], msg: "wrong number of arguments"}
+	}
+
+	target := ""
+	switch {
+	case len(args) == 0:
+	case args[0] == "-":
+		target = sh.previous
+	default:
+		target = sh.a.remotePath(args[0])
+	}
+
+	meta, err := sh.a.lookup(target)
+	if err != nil {
+		return err
+	}
+	if !meta.IsDir() {
+		return fmt.Errorf("%s is not a directory", target)
+	}
+
+	sh.previous, sh.a.cwd = sh.a.cwd, target
+	return nil
+}
+
+// Completes the word under the cursor: the first word with the command
+// names, the rest with the remote paths
+func (sh *shell) complete(line string, pos int) (head string, completions []string, tail string) {
+	head, tail = line[:pos], line[pos:]
+	start := wordStart(head)
+	word := head[start:]
+	head = head[:start]
+
+	if previous, err := splitArgs(head); err == nil && len(previous) == 0 {
+		names := append(sortedNames(commands), sortedNames(shellCommands)...)
+		for _, name := range names {
+			if strings.HasPrefix(name, word) {
+				completions = append(completions, name+" ")
+			}
+		}
+		return head, completions, tail
+	}
+
+	// The partial path without the escapes
+	p := word
+	if args, err := splitArgs(word); err == nil && len(args) == 1 {
+		p = args[0]
+	}
+
+	dir := p[:strings.LastIndex(p, "/")+1]
+	prefix := p[len(dir):]
+
+	children, err := sh.a.listDir(sh.a.remotePath(dir))
+	if err != nil {
+		return head, nil, tail
+	}
+
+	for _, child := range children {
+		if !strings.HasPrefix(child.Name, prefix) {
+			continue
+		}
+
+		if child.IsDir() {
+			completions = append(completions, escapeArg(dir+child.Name)+"/")
+		} else {
+			completions = append(completions, escapeArg(dir+child.Name)+" ")
+		}
+	}
+	sort.Strings(completions)
+
+	return head, completions, tail
+}
+
+func sortedNames(cmds map[string]*command) []string {
+	names := make([]string, 0, len(cmds))
+	for name := range cmds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Splits a command line in words, the words can be quoted with single
+// quotes (literal) or double quotes and the characters escaped with a
+// backslash
+func splitArgs(line string) ([]string, error) {
+	args := []string{}
+	var current strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\\':
+			escaped = true
+			inWord = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				args = append(args, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, errors.New("Unterminated quote or escape")
+	}
+
+	if inWord {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
+
+// Returns the start of the last word of the line
+func wordStart(line string) int {
+	start := 0
+	var quote rune
+	escaped := false
+
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == ' ' || r == '\t':
+			start = i + 1
+		}
+	}
+
+	return start
+}
+
+// Escapes the characters that split the words
+func escapeArg(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(" \t'\"\\", r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
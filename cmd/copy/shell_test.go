@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"", []string{}},
+		{"ls  -l photos", []string{"ls", "-l", "photos"}},
+		{`get "my photos/a b.jpg" .`, []string{"get", "my photos/a b.jpg", "."}},
+		{`get my\ photos/a\ b.jpg`, []string{"get", "my photos/a b.jpg"}},
+		{`rm 'it\s' ""`, []string{"rm", `it\s`, ""}},
+		{`mv "say \"hi\"" x`, []string{"mv", `say "hi"`, "x"}},
+	}
+
+	for _, test := range tests {
+		got, err := splitArgs(test.line)
+		if err != nil {
+			t.Errorf("Could not split %q: %v", test.line, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Wrong split of %q: got %q, want %q", test.line, got, test.want)
+		}
+	}
+
+	if _, err := splitArgs(`get "unterminated`); err == nil {
+		t.Error("Unterminated quote should fail")
+	}
+}
+
+func TestWordStartAndEscape(t *testing.T) {
+	tests := []struct {
+		line string
+		want int
+	}{
+		{"", 0},
+		{"ls", 0},
+		{"ls ", 3},
+		{"get my\\ pho", 4},
+		{`get "my pho`, 4},
+	}
+
+	for _, test := range tests {
+		if got := wordStart(test.line); got != test.want {
+			t.Errorf("Wrong word start of %q: got %d, want %d", test.line, got, test.want)
+		}
+	}
+
+	if got := escapeArg(`my "photos" 1`); got != `my\ \"photos\"\ 1` {
+		t.Errorf("Wrong escape: %s", got)
+	}
+}
+
+// Serves a tree with photos/trips/ and photos/a b.jpg, returns the number
+// of listings
+func setupShellTree(t *testing.T) (*shell, *int) {
+	setup(t)
+
+	listings := 0
+	mux.HandleFunc("/meta/copy", func(w http.ResponseWriter, r *http.Request) {
+		listings++
+		fmt.Fprint(w, `{"path":"/","type":"root","children_count":2,"children":[
+			{"name":"photos","path":"/photos","type":"dir"},
+			{"name":"notes.txt","path":"/notes.txt","type":"file"}
+		]}`)
+	})
+	mux.HandleFunc("/meta/copy/photos", func(w http.ResponseWriter, r *http.Request) {
+		listings++
+		fmt.Fprint(w, `{"path":"/photos","type":"dir","children_count":3,"children":[
+			{"name":"trips","path":"/photos/trips","type":"dir"},
+			{"name":"a b.jpg","path":"/photos/a b.jpg","type":"file"},
+			{"name":"tree.jpg","path":"/photos/tree.jpg","type":"file"}
+		]}`)
+	})
+	mux.HandleFunc("/meta/copy/photos/trips", func(w http.ResponseWriter, r *http.Request) {
+		listings++
+		fmt.Fprint(w, `{"path":"/photos/trips","type":"dir","children_count":0,"children":[]}`)
+	})
+
+	return newShell(testApp), &listings
+}
+
+func TestShellCd(t *testing.T) {
+	sh, _ := setupShellTree(t)
+	defer tearDown()
+
+	for _, line := range []string{"cd photos", "cd trips", "pwd", "cd ..", "pwd", "cd -", "pwd", "cd /", "pwd"} {
+		sh.execLine(line)
+	}
+
+	if got := stdout.String(); got != "/photos/trips\n/photos\n/photos/trips\n/\n" {
+		t.Errorf("Wrong working directories: %q", got)
+	}
+
+	sh.execLine("cd notes.txt")
+	sh.execLine("cd missing")
+	if sh.a.cwd != "" {
+		t.Errorf("Wrong working directory after the failed cds: %q", sh.a.cwd)
+	}
+	if got := stderr.String(); got != "copy: notes.txt is not a directory\ncopy: missing: Not found\n" {
+		t.Errorf("Wrong errors: %q", got)
+	}
+
+	if !sh.execLine("exit") {
+		t.Error("exit should exit the shell")
+	}
+}
+
+func TestShellListingsCache(t *testing.T) {
+	sh, listings := setupShellTree(t)
+	defer tearDown()
+
+	sh.execLine("cd photos")
+	sh.execLine("ls")
+	sh.execLine("ls /photos")
+	if *listings != 2 {
+		t.Errorf("Listings should be cached, got %d", *listings)
+	}
+	if got := stdout.String(); got != "trips/\na b.jpg\ntree.jpg\ntrips/\na b.jpg\ntree.jpg\n" {
+		t.Errorf("Wrong ls output: %q", got)
+	}
+
+	// The changes clear the cache
+	mux.HandleFunc("/files/photos/new", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{}`)
+	})
+	sh.execLine("mkdir new")
+	sh.execLine("ls")
+	if *listings != 3 {
+		t.Errorf("Listings should be fetched after a change, got %d", *listings)
+	}
+}
+
+func TestShellComplete(t *testing.T) {
+	sh, _ := setupShellTree(t)
+	defer tearDown()
+
+	tests := []struct {
+		line string
+		pos  int
+		head string
+		want []string
+		tail string
+	}{
+		{"re", 2, "", []string{"rename ", "revisions "}, ""},
+		{"ls ph", 5, "ls ", []string{"photos/"}, ""},
+		{"ls photos/t", 11, "ls ", []string{"photos/tree.jpg ", "photos/trips/"}, ""},
+		{"get photos/a", 12, "get ", []string{`photos/a\ b.jpg `}, ""},
+		{"get photos/a . -x", 12, "get ", []string{`photos/a\ b.jpg `}, " . -x"},
+		{"ls missing/", 11, "ls ", nil, ""},
+	}
+
+	for _, test := range tests {
+		head, got, tail := sh.complete(test.line, test.pos)
+		if head != test.head || tail != test.tail || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Wrong completion of %q: got %q %q %q, want %q %q %q",
+				test.line, head, got, tail, test.head, test.want, test.tail)
+		}
+	}
+
+	// Relative to the working directory
+	sh.execLine("cd photos")
+	if _, got, _ := sh.complete("cd tr", 5); !reflect.DeepEqual(got, []string{"tree.jpg ", "trips/"}) {
+		t.Errorf("Wrong relative completion: %q", got)
+	}
+}
+
+func TestShellHistory(t *testing.T) {
+	sh, _ := setupShellTree(t)
+	defer tearDown()
+
+	sh.execLine("pwd")
+	sh.execLine("cd photos")
+	sh.execLine("history")
+
+	if got := stdout.String(); got != "/\n   1  pwd\n   2  cd photos\n   3  history\n" {
+		t.Errorf("Wrong history: %q", got)
+	}
+}
+
+func TestShellHelp(t *testing.T) {
+	sh, _ := setupShellTree(t)
+	defer tearDown()
+
+	for _, name := range []string{"cd", "pwd", "history", "exit"} {
+		stderr.Reset()
+		sh.execLine("help " + name)
+
+		want := "Usage: copy " + name + " " + shellCommands[name].args + "\n\n" + shellCommands[name].help + "\n"
+		if got := stderr.String(); got != want {
+			t.Errorf("Wrong help of %s: %q", name, got)
+		}
+	}
+
+	// The help of the commands
+	stderr.Reset()
+	sh.execLine("help ls")
+	if !strings.HasPrefix(stderr.String(), "Usage: copy ls") {
+		t.Errorf("Wrong help of ls: %q", stderr.String())
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/slok/go-copy/sync"
+)
+
+// The sync report in JSON mode
+type syncResult struct {
+	DryRun     bool              `json:"dry_run"`
+	Counts     map[string]int    `json:"counts"`
+	Conflicts  []string          `json:"conflicts"`
+	Failed     map[string]string `json:"failed"`
+	Uploaded   int64             `json:"bytes_uploaded"`
+	Downloaded int64             `json:"bytes_downloaded"`
+	Duration   float64           `json:"duration_seconds"`
+}
+
+var syncStrategies = map[string]sync.ConflictStrategy{
+	sync.KeepBoth.String():   sync.KeepBoth,
+	sync.KeepLocal.String():  sync.KeepLocal,
+	sync.KeepRemote.String(): sync.KeepRemote,
+	sync.Skip.String():       sync.Skip,
+}
+
+func cmdSync(a *app, args []string) error {
+	flags := a.flagSet("sync")
+	twoWay := flags.Bool("two-way", false, "Syncs the changes of both sides, by default the remote directory mirrors the local one")
+	dryRun := flags.Bool("dry-run", false, "Only shows what would be done")
+	noDelete := flags.Bool("no-delete", false, "Doesn't delete the remote files that don't exist locally (mirror)")
+	concurrency := flags.Int("concurrency", 4, "Number of transfers at the same time")
+	strategy := flags.String("strategy", sync.KeepBoth.String(), "Resolution of the conflicts (two-way): keep-both, keep-local, keep-remote or skip")
+	statePath := flags.String("state", "", "State file of the two-way sync, LOCAL/.copy-sync.json by default")
+	args, err := a.parseFlags(flags, args, 2, 2)
+	if err != nil {
+		return err
+	}
+
+	local, remote := args[0], a.remotePath(args[1])
+	execOpts := sync.ExecuteOptions{Concurrency: *concurrency, DryRun: *dryRun}
+
+	if !a.json {
+		execOpts.OnResult = func(res sync.Result) {
+			if res.Err != nil {
+				fmt.Fprintf(a.stderr, "failed %s: %v\n", res.Action, res.Err)
+			} else {
+				fmt.Fprintln(a.stdout, res.Action)
+			}
+		}
+	}
+
+	var report *sync.Report
+	if *twoWay {
+		conflictStrategy, ok := syncStrategies[*strategy]
+		if !ok {
+			return usagef("sync", "unknown strategy %s", *strategy)
+		}
+
+		report, err = sync.Bidirectional(a.ctx, a.files, local, remote, &sync.BidirectionalOptions{
+			ExecuteOptions: execOpts,
+			StatePath:      *statePath,
+			Strategy:       conflictStrategy,
+		})
+	} else {
+		report, err = sync.Mirror(a.ctx, a.files, local, remote, &sync.MirrorOptions{
+			ExecuteOptions: execOpts,
+			NoDelete:       *noDelete,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	result := newSyncResult(report)
+	if err := a.output(result, func(w io.Writer) { fmt.Fprintln(w, report) }); err != nil {
+		return err
+	}
+
+	return report.Err()
+}
+
+func newSyncResult(report *sync.Report) *syncResult {
+	result := &syncResult{
+		DryRun:     report.DryRun,
+		Counts:     map[string]int{},
+		Conflicts:  []string{},
+		Failed:     map[string]string{},
+		Uploaded:   report.BytesUploaded(),
+		Downloaded: report.BytesDownloaded(),
+		Duration:   report.Duration.Round(time.Millisecond).Seconds(),
+	}
+
+	for _, res := range report.Results {
+		if res.Err != nil {
+			result.Failed[res.Action.String()] = res.Err.Error()
+		} else {
+			result.Counts[res.Action.Type.String()]++
+		}
+	}
+
+	for _, c := range report.Plan.Conflicts {
+		result.Conflicts = append(result.Conflicts, c.Path)
+	}
+
+	return result
+}
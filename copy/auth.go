@@ -0,0 +1,143 @@
+package copy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/garyburd/go-oauth/oauth"
+)
+
+// Oauth 1.0a three legged flow endpoints
+const (
+	RequestTokenURL = "https://api.copy.com/oauth/request"
+	AccessTokenURL  = "https://api.copy.com/oauth/access"
+)
+
+// Authorizer gets the access token of a user for the app with the three
+// legged Oauth flow:
+//
+//  1. Get a request token with RequestToken
+//  2. Send the user to the AuthorizeURL of the request token
+//  3. Exchange the request token and the verifier that Copy gives to the
+//     user (or to the callback) for the access token with AccessToken
+//
+// For CLIs AuthorizeWithLoopback does all the steps with a local callback
+// server
+type Authorizer struct {
+	OauthClient oauth.Client
+	HttpClient  *http.Client
+}
+
+// Creates a new authorizer for the app
+func NewAuthorizer(appToken AppToken) (*Authorizer, error) {
+	if appToken.Token == "" || appToken.Key == "" {
+		return nil, errors.New("Could not create the authorizer, Check app settings")
+	}
+
+	return &Authorizer{
+		OauthClient: oauth.Client{
+			TemporaryCredentialRequestURI: RequestTokenURL,
+			ResourceOwnerAuthorizationURI: AuthURL,
+			TokenRequestURI:               AccessTokenURL,
+			Credentials: oauth.Credentials{
+				Token:  appToken.Token,
+				Secret: appToken.Key,
+			},
+		},
+		HttpClient: defaultHttpClient,
+	}, nil
+}
+
+// Gets a request token (temporary credentials), Copy will redirect the user
+// to the callback URL after authorizing the app. Use "oob" as callback if
+// there is no callback, the user will need to copy the verifier by hand
+func (a *Authorizer) RequestToken(callbackURL string) (*oauth.Credentials, error) {
+	creds, err := a.OauthClient.RequestTemporaryCredentials(a.HttpClient, callbackURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Could not get the request token: %v", err)
+	}
+
+	return creds, nil
+}
+
+// Returns the URL where the user authorizes the app
+func (a *Authorizer) AuthorizeURL(requestToken *oauth.Credentials) string {
+	return a.OauthClient.AuthorizationURL(requestToken, nil)
+}
+
+// Exchanges the authorized request token and the verifier for the access
+// token of the user
+func (a *Authorizer) AccessToken(requestToken *oauth.Credentials, verifier string) (*AccessToken, error) {
+	if verifier == "" {
+		return nil, errors.New("Missing oauth verifier")
+	}
+
+	creds, _, err := a.OauthClient.RequestToken(a.HttpClient, requestToken, verifier)
+	if err != nil {
+		return nil, fmt.Errorf("Could not get the access token: %v", err)
+	}
+
+	return &AccessToken{
+		Token: creds.Token,
+		Key:   creds.Secret,
+	}, nil
+}
+
+// Does all the three legged flow with a local callback server listening in
+// addr (127.0.0.1:0 if empty). The open function receives the authorize URL,
+// it should open a browser or show the URL to the user. Blocks until Copy
+// redirects the user to the callback or the context is done
+func (a *Authorizer) AuthorizeWithLoopback(ctx context.Context, addr string, open func(authorizeURL string) error) (*AccessToken, error) {
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer listener.Close()
+
+	callbackURL := fmt.Sprintf("http://%s/callback", listener.Addr().String())
+
+	requestToken, err := a.RequestToken(callbackURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// The callback will receive the verifier of the request token
+	verifiers := make(chan string, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("oauth_token") != requestToken.Token || query.Get("oauth_verifier") == "" {
+			http.Error(w, "Wrong authorization", http.StatusBadRequest)
+			return
+		}
+
+		fmt.Fprint(w, "The app has been authorized, you can close this window")
+
+		select {
+		case verifiers <- query.Get("oauth_verifier"):
+		default:
+		}
+	})
+
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+	defer server.Close()
+
+	if err := open(a.AuthorizeURL(requestToken)); err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case verifier := <-verifiers:
+		return a.AccessToken(requestToken, verifier)
+	}
+}
@@ -0,0 +1,164 @@
+package copy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	authorizer *Authorizer
+)
+
+// Setups the authorizer with the mock server Oauth endpoints
+func setupAuthorizer(t *testing.T) {
+	setup(t)
+
+	var err error
+	authorizer, err = NewAuthorizer(AppToken{Token: "apptoken", Key: "appsecret"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	authorizer.OauthClient.TemporaryCredentialRequestURI = server.URL + "/oauth/request"
+	authorizer.OauthClient.ResourceOwnerAuthorizationURI = server.URL + "/applications/authorize"
+	authorizer.OauthClient.TokenRequestURI = server.URL + "/oauth/access"
+
+	mux.HandleFunc("/oauth/request",
+		func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, "POST")
+			if oauthParams(r).Get("oauth_callback") == "" {
+				t.Errorf("Missing callback")
+			}
+			fmt.Fprint(w, "oauth_token=requesttoken&oauth_token_secret=requestsecret&oauth_callback_confirmed=true")
+		},
+	)
+
+	mux.HandleFunc("/oauth/access",
+		func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, "POST")
+			if oauthParams(r).Get("oauth_verifier") != "verifier" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, "oauth_token=accesstoken&oauth_token_secret=accesssecret")
+		},
+	)
+}
+
+// Returns the oauth params of the Authorization header of the request
+// (OAuth oauth_callback="...", oauth_verifier="...")
+func oauthParams(r *http.Request) url.Values {
+	params := url.Values{}
+
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "OAuth ") {
+		return params
+	}
+
+	for _, param := range strings.Split(strings.TrimPrefix(header, "OAuth "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, _ := url.QueryUnescape(kv[0])
+		value, _ := url.QueryUnescape(strings.Trim(kv[1], `"`))
+		params.Set(key, value)
+	}
+
+	return params
+}
+
+func tearDownAuthorizer() {
+	defer tearDown()
+}
+
+func TestAuthorizerFlow(t *testing.T) {
+	setupAuthorizer(t)
+	defer tearDownAuthorizer()
+
+	requestToken, err := authorizer.RequestToken("oob")
+	if err != nil {
+		t.Fatalf("Shouldn't be an error: %v", err)
+	}
+
+	if requestToken.Token != "requesttoken" || requestToken.Secret != "requestsecret" {
+		t.Errorf("Wrong request token: %#v", requestToken)
+	}
+
+	authURL, _ := url.Parse(authorizer.AuthorizeURL(requestToken))
+	if authURL.Query().Get("oauth_token") != "requesttoken" {
+		t.Errorf("Wrong authorize URL: %s", authURL)
+	}
+
+	accessToken, err := authorizer.AccessToken(requestToken, "verifier")
+	if err != nil {
+		t.Fatalf("Shouldn't be an error: %v", err)
+	}
+
+	if *accessToken != (AccessToken{Token: "accesstoken", Key: "accesssecret"}) {
+		t.Errorf("Wrong access token: %#v", accessToken)
+	}
+
+	if _, err := authorizer.AccessToken(requestToken, "wrong"); err == nil {
+		t.Errorf("Wrong verifier, should be an error")
+	}
+
+	if _, err := NewAuthorizer(AppToken{}); err == nil {
+		t.Errorf("Missing app token, should be an error")
+	}
+}
+
+func TestAuthorizeWithLoopback(t *testing.T) {
+	setupAuthorizer(t)
+	defer tearDownAuthorizer()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The user authorizes the app and Copy redirects to the callback
+	callbackURL := ""
+	open := func(authorizeURL string) error {
+		u, _ := url.Parse(authorizeURL)
+		token := u.Query().Get("oauth_token")
+
+		go func() {
+			// Wrong redirections are ignored
+			http.Get(fmt.Sprintf("%s?oauth_token=%s&oauth_verifier=verifier", callbackURL, "wrong"))
+			http.Get(fmt.Sprintf("%s?oauth_token=%s&oauth_verifier=verifier", callbackURL, token))
+		}()
+		return nil
+	}
+
+	// The callback URL is sent when requesting the token
+	mux.HandleFunc("/oauth/request/loopback",
+		func(w http.ResponseWriter, r *http.Request) {
+			callbackURL = oauthParams(r).Get("oauth_callback")
+			fmt.Fprint(w, "oauth_token=requesttoken&oauth_token_secret=requestsecret&oauth_callback_confirmed=true")
+		},
+	)
+	authorizer.OauthClient.TemporaryCredentialRequestURI = server.URL + "/oauth/request/loopback"
+
+	accessToken, err := authorizer.AuthorizeWithLoopback(ctx, "", open)
+	if err != nil {
+		t.Fatalf("Shouldn't be an error: %v", err)
+	}
+
+	if *accessToken != (AccessToken{Token: "accesstoken", Key: "accesssecret"}) {
+		t.Errorf("Wrong access token: %#v", accessToken)
+	}
+
+	// Never authorized
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = authorizer.AuthorizeWithLoopback(ctx, "", func(string) error { return nil })
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Should be a deadline error: %v", err)
+	}
+}
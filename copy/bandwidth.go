@@ -0,0 +1,124 @@
+package copy
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// BandwidthLimiter limits the bytes per second of the transfers with a token
+// bucket. A limiter can be shared by many transfers, the limit will be for all
+// of them (set it in the client with WithBandwidthLimiter for a global limit
+// or in the options of the uploads and downloads for a limit per call)
+type BandwidthLimiter struct {
+	mu sync.Mutex
+
+	bytesPerSecond float64
+	burst          int64
+	tokens         float64
+	last           time.Time
+}
+
+// Creates a limiter of bytesPerSecond, the transfers can burst up to a
+// quarter of a second of bytes
+func NewBandwidthLimiter(bytesPerSecond int64) *BandwidthLimiter {
+	if bytesPerSecond <= 0 {
+		bytesPerSecond = 1
+	}
+
+	burst := bytesPerSecond / 4
+	if burst <= 0 {
+		burst = 1
+	}
+
+	return &BandwidthLimiter{
+		bytesPerSecond: float64(bytesPerSecond),
+		burst:          burst,
+		tokens:         float64(burst),
+		last:           time.Now(),
+	}
+}
+
+// Waits until n bytes can be transferred or the context is done. The bytes
+// are always taken, so big transfers wait more for the next ones
+func (l *BandwidthLimiter) WaitN(ctx context.Context, n int) error {
+	l.mu.Lock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.bytesPerSecond
+	if l.tokens > float64(l.burst) {
+		l.tokens = float64(l.burst)
+	}
+	l.last = now
+
+	l.tokens -= float64(n)
+	wait := time.Duration(0)
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.bytesPerSecond * float64(time.Second))
+	}
+
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Returns a reader limited by the limiter, a nil limiter returns the same
+// reader
+func (l *BandwidthLimiter) Reader(ctx context.Context, r io.Reader) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &bandwidthReader{ctx: ctx, r: r, limiter: l}
+}
+
+// Like Reader but keeps the closer
+func (l *BandwidthLimiter) ReadCloser(ctx context.Context, rc io.ReadCloser) io.ReadCloser {
+	if l == nil {
+		return rc
+	}
+	return &bandwidthReadCloser{
+		Reader: l.Reader(ctx, rc),
+		Closer: rc,
+	}
+}
+
+// Waits for the limiter after every read, the reads are never bigger than
+// the burst of the limiter
+type bandwidthReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *BandwidthLimiter
+}
+
+func (b *bandwidthReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > b.limiter.burst {
+		p = p[:b.limiter.burst]
+	}
+
+	n, err := b.r.Read(p)
+	if n > 0 {
+		if werr := b.limiter.WaitN(b.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+
+	return n, err
+}
+
+type bandwidthReadCloser struct {
+	io.Reader
+	io.Closer
+}
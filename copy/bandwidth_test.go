@@ -0,0 +1,99 @@
+package copy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBandwidthLimiterReader(t *testing.T) {
+	// 256KB of burst, the other 256KB need 250ms
+	limiter := NewBandwidthLimiter(1024 * 1024)
+	content := bytes.Repeat([]byte("a"), 512*1024)
+
+	start := time.Now()
+	n, err := io.Copy(ioutil.Discard, limiter.Reader(context.Background(), bytes.NewReader(content)))
+	elapsed := time.Since(start)
+
+	if err != nil || n != int64(len(content)) {
+		t.Fatalf("Wrong read: %d, %v", n, err)
+	}
+
+	if elapsed < 200*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("Wrong limited read time: %s", elapsed)
+	}
+
+	// Nil limiters don't limit
+	r := bytes.NewReader(content)
+	var nilLimiter *BandwidthLimiter
+	if nilLimiter.Reader(context.Background(), r) != r {
+		t.Errorf("Nil limiter should return the same reader")
+	}
+}
+
+func TestBandwidthLimiterContext(t *testing.T) {
+	limiter := NewBandwidthLimiter(1024)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// The first read takes all the tokens
+	limiter.WaitN(ctx, 1024)
+
+	if err := limiter.WaitN(ctx, 1024); err != context.DeadlineExceeded {
+		t.Errorf("Should be a deadline error: %v", err)
+	}
+}
+
+func TestClientBandwidthLimiter(t *testing.T) {
+	setupFileService(t)
+	defer tearDownFileService()
+
+	content := bytes.Repeat([]byte("a"), 512*1024)
+
+	mux.HandleFunc(strings.Join([]string{"", filesTopLevelSuffix, "big.bin"}, "/"),
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write(content)
+		},
+	)
+
+	mux.HandleFunc(strings.Join([]string{"", filesTopLevelSuffix, "uploads"}, "/"),
+		func(w http.ResponseWriter, r *http.Request) {
+			io.Copy(ioutil.Discard, r.Body)
+		},
+	)
+
+	// Global limit for the downloads
+	client.SetBandwidthLimiter(NewBandwidthLimiter(1024 * 1024))
+	defer client.SetBandwidthLimiter(nil)
+
+	start := time.Now()
+	r, err := fileService.GetFile("big.bin")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	io.Copy(ioutil.Discard, r)
+	r.Close()
+
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("Download should be limited: %s", elapsed)
+	}
+
+	// Limit per upload
+	client.SetBandwidthLimiter(nil)
+
+	start = time.Now()
+	opts := &UploadOptions{BandwidthLimiter: NewBandwidthLimiter(1024 * 1024)}
+	if err := fileService.UploadReader(bytes.NewReader(content), int64(len(content)), "uploads/big.bin", opts); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("Upload should be limited: %s", elapsed)
+	}
+}
@@ -23,14 +23,14 @@ package copy
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"mime/multipart"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
 )
 
@@ -40,6 +40,9 @@ type Client struct {
 	session      *Session
 	resourcesUrl string
 	httpClient   *http.Client
+
+	// Limits the uploads and downloads of all the requests
+	bandwidth *BandwidthLimiter
 }
 
 const (
@@ -67,50 +70,39 @@ var (
 )
 
 // Creates a new client. If no http client and URL the client will use the
-// default ones
+// default ones. See New for more options
 func NewClient(httpClient *http.Client, resourcesUrl string,
 	appToken string, appSecret string,
 	accessToken string, accessSecret string) (*Client, error) {
 
-	c := new(Client)
-
-	if httpClient == nil {
-		c.httpClient = defaultHttpClient
-	} else {
-		c.httpClient = httpClient
-	}
-
-	if resourcesUrl == "" {
-		c.resourcesUrl = defaultResourcesUrl
-	} else {
-		c.resourcesUrl = resourcesUrl
-	}
-
-	session, err := NewSession(
-		AppToken{
-			Token: appToken,
-			Key:   appSecret,
-		},
-		AccessToken{
-			Token: accessToken,
-			Key:   accessSecret,
-		},
+	return New(
+		WithHTTPClient(httpClient),
+		WithBaseURL(resourcesUrl),
+		WithCredentials(appToken, appSecret, accessToken, accessSecret),
 	)
-
-	if err != nil || appToken == "" || appSecret == "" || accessToken == "" ||
-		accessSecret == "" {
-		return nil, errors.New("Could not create the client, Check access settings")
-	}
-
-	c.session = session
-	return c, nil
-
 }
 
 // Returns a default client, normally we will use this
 func NewDefaultClient(appToken string, appSecret string,
 	accessToken string, accessSecret string) (*Client, error) {
-	return NewClient(nil, "", appToken, appSecret, accessToken, accessSecret)
+	return New(WithCredentials(appToken, appSecret, accessToken, accessSecret))
+}
+
+// Sets the policy for retrying the failed requests of the client, nil
+// disables the retries
+func (c *Client) SetRetryPolicy(policy *RetryPolicy) {
+	c.session.RetryPolicy = policy
+}
+
+// Sets the rate limiter of the requests of the client, nil removes the limit
+func (c *Client) SetRateLimiter(rateLimiter RateLimiter) {
+	c.session.RateLimiter = rateLimiter
+}
+
+// Sets the bandwidth limiter of all the uploads and downloads of the client,
+// nil removes the limit
+func (c *Client) SetBandwidthLimiter(limiter *BandwidthLimiter) {
+	c.bandwidth = limiter
 }
 
 // Makes the client request based on the url, method, values and returns
@@ -118,6 +110,11 @@ func NewDefaultClient(appToken string, appSecret string,
 // the value is inside the v param (you should pass a pointer because will
 // mutate inside the method)
 func (c *Client) DoRequestDecoding(method string, urlStr string, form url.Values, v interface{}) (*http.Response, error) {
+	return c.DoRequestDecodingContext(context.Background(), method, urlStr, form, v)
+}
+
+// Like DoRequestDecoding but the request is made with the context
+func (c *Client) DoRequestDecodingContext(ctx context.Context, method string, urlStr string, form url.Values, v interface{}) (*http.Response, error) {
 	var resp *http.Response
 	var err error
 
@@ -125,25 +122,31 @@ func (c *Client) DoRequestDecoding(method string, urlStr string, form url.Values
 
 	switch method {
 	case "GET":
-		resp, err = c.session.Get(endpoint, form, c.httpClient)
+		resp, err = c.session.GetContext(ctx, endpoint, form, c.httpClient)
 
 	case "POST":
-		resp, err = c.session.Post(endpoint, form, c.httpClient)
+		resp, err = c.session.PostContext(ctx, endpoint, form, c.httpClient)
 
 	case "PUT":
-		resp, err = c.session.Put(endpoint, form, c.httpClient)
+		resp, err = c.session.PutContext(ctx, endpoint, form, c.httpClient)
 
 	case "DELETE":
-		resp, err = c.session.Delete(endpoint, form, c.httpClient)
+		resp, err = c.session.DeleteContext(ctx, endpoint, form, c.httpClient)
 
+	default:
+		return nil, fmt.Errorf("Wrong request method: %s", method)
 	}
 
-	if err != nil || resp == nil {
-		return nil, errors.New("Error making the request")
+	if err != nil {
+		return nil, &APIError{Method: method, URL: endpoint, Err: err}
 	}
 
 	defer resp.Body.Close()
 
+	if err := checkResponse(resp); err != nil { // 400s and 500s
+		return resp, err
+	}
+
 	// If v is nil that means that the caller doesn't need the response
 	if v != nil {
 		// response body to string
@@ -159,10 +162,6 @@ func (c *Client) DoRequestDecoding(method string, urlStr string, form url.Values
 		json.NewDecoder(strings.NewReader(respBody)).Decode(v)
 	}
 
-	if resp.StatusCode >= 400 { // 400s and 500s
-		return resp, errors.New(fmt.Sprintf("Client response: %d", resp.StatusCode))
-	}
-
 	return resp, nil
 }
 
@@ -170,102 +169,279 @@ func (c *Client) DoRequestDecoding(method string, urlStr string, form url.Values
 //
 // This will be binary data body so we don't process the request
 func (c *Client) DoRequestContent(urlStr string, form url.Values) (*http.Response, error) {
+	return c.DoRequestContentContext(context.Background(), urlStr, form)
+}
+
+// Like DoRequestContent but the request is made with the context, the
+// cancellation of the context will stop reading the response body
+func (c *Client) DoRequestContentContext(ctx context.Context, urlStr string, form url.Values) (*http.Response, error) {
 	var resp *http.Response
 	var err error
 
 	endpoint := strings.Join([]string{c.resourcesUrl, urlStr}, "/")
 
-	resp, err = c.session.Get(endpoint, form, c.httpClient)
+	resp, err = c.session.GetContext(ctx, endpoint, form, c.httpClient)
 
-	if err != nil || resp == nil {
-		return nil, errors.New("Error making the request")
+	if err != nil {
+		return nil, &APIError{Method: "GET", URL: endpoint, Err: err}
 	}
 
 	// Don't close the body is a chunked HTTP response
 	//defer resp.Body.Close()
 
-	if resp.StatusCode >= 400 { // 400s and 500s
-		return resp, errors.New(fmt.Sprintf("Client response: %d", resp.StatusCode))
+	if err := checkResponse(resp); err != nil { // 400s and 500s
+		return resp, err
+	}
+
+	resp.Body = c.bandwidth.ReadCloser(ctx, resp.Body)
+
+	return resp, nil
+}
+
+// Like DoRequestContentContext but only asks for the bytes between start and
+// end (both included), a negative end means until the end. If the server
+// ignores the range the response status will be 200 instead of 206
+func (c *Client) DoRequestContentRangeContext(ctx context.Context, urlStr string, form url.Values, start, end int64) (*http.Response, error) {
+	endpoint := strings.Join([]string{c.resourcesUrl, urlStr}, "/")
+
+	resp, err := c.session.GetRangeContext(ctx, endpoint, form, start, end, c.httpClient)
+
+	if err != nil {
+		return nil, &APIError{Method: "GET", URL: endpoint, Err: err}
 	}
 
+	if err := checkResponse(resp); err != nil { // 400s and 500s
+		return resp, err
+	}
+
+	resp.Body = c.bandwidth.ReadCloser(ctx, resp.Body)
+
 	return resp, nil
 }
 
 // Makes the client request for uploading multipart request
 //
+// The file is streamed from disk, it is never loaded in memory
 func (c *Client) DoRequestMultipart(filePath, uploadPath, filename, method string) (*http.Response, error) {
+	return c.DoRequestMultipartContext(context.Background(), filePath, uploadPath, filename, method)
+}
 
-	endpoint := strings.Join([]string{c.resourcesUrl, uploadPath}, "/")
-
-	// Do sequential upload (not all in memory)
+// Like DoRequestMultipart but the request is made with the context, the
+// cancellation of the context will stop the upload
+func (c *Client) DoRequestMultipartContext(ctx context.Context, filePath, uploadPath, filename, method string) (*http.Response, error) {
 
 	// Get our file reader
-	file, err := os.Open(filePath)
+	file, size, err := openUploadFile(filePath)
 	if err != nil {
 		return nil, err
 	}
+	defer file.Close()
 
-	// The key is to use a pipe
-	// (reading the file will feed the writer and then the reader will be in the multipart body
-	// so when the multipart request a chunk the reader will "call" the writer and this will
-	// read from the file)
-	//
-	// File -> FileReader -> Writer -> (Multipart wrapp magic) -> Reader -> Multipart body
-	/*reader, writer := io.Pipe()
-	multiWriter := multipart.NewWriter(writer)
-
-	// The sequential write (read from file) will be in a goroutine
-	go func() {
-		defer writer.Close()
-		defer file.Close()
+	return c.DoRequestMultipartReaderContext(ctx, file, size, uploadPath, filename, method)
+}
 
-		part, _ := multiWriter.CreateFormFile("file", filename)
+// Makes the client request for uploading multipart request with the content
+// of the reader. The size is the number of bytes that the reader has, if it
+// is unknown use a negative size and the request will be sent chunked.
+//
+// The reader is streamed, it is never loaded in memory and it is not closed
+func (c *Client) DoRequestMultipartReader(r io.Reader, size int64, uploadPath, filename, method string) (*http.Response, error) {
+	return c.DoRequestMultipartReaderContext(context.Background(), r, size, uploadPath, filename, method)
+}
 
-		// Copy on demand
-		io.Copy(part, file)
-		multiWriter.Close()
-	}()
+// Like DoRequestMultipartReader but the request is made with the context, the
+// cancellation of the context will stop the upload
+func (c *Client) DoRequestMultipartReaderContext(ctx context.Context, r io.Reader, size int64, uploadPath, filename, method string) (*http.Response, error) {
+	return c.doRequestMultipart(ctx, r, size, uploadPath, filename, method, nil)
+}
 
-	// This will be custom because the multipart is trickier thatn a normal request
-	req, err := http.NewRequest("POST", endpoint, reader)
-	if err != nil {
-		return nil, err
-	}*/
+// Makes the multipart request with the upload options (progress...)
+func (c *Client) doRequestMultipart(ctx context.Context, r io.Reader, size int64, uploadPath, filename, method string, opts *UploadOptions) (*http.Response, error) {
 
-	//-----------------------------------------------------------
-	// FIXME: See above, use pipes to read/write and don't load in memory
-	// From the docs: The maximum filesize of an upload is 1GB. An API endpoint
-	// supporting chunked file uploading is planned for circumventing this limitation.
-	//defer file.Close()
+	endpoint := strings.Join([]string{c.resourcesUrl, uploadPath}, "/")
 
-	body := &bytes.Buffer{}
-	multiWriter := multipart.NewWriter(body)
-	part, err := multiWriter.CreateFormFile("file", filename)
+	body, err := newMultipartBody(c.uploadContentReader(ctx, r, size, opts), size, filename, "")
 	if err != nil {
 		return nil, err
 	}
-	_, err = io.Copy(part, file)
-	multiWriter.Close()
-	req, err := http.NewRequest(method, endpoint, body)
+
+	// This will be custom because the multipart is trickier thatn a normal request
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
 	if err != nil {
+		body.Close()
 		return nil, err
 	}
-	//-----------------------------------------------------------
 
-	fileInfo, _ := file.Stat()
-	req.Header.Set("Authorization", c.session.OauthClient.AuthorizationHeader(&c.session.TokenCreds, method, req.URL, nil))
-	req.Header.Set("Content-Type", multiWriter.FormDataContentType())
-	req.Header.Set("Content-Length", fmt.Sprintf("%d", fileInfo.Size()))
+	req.ContentLength = body.contentLength
+	req.Header.Set("Content-Type", body.contentType)
+
+	// If we can go back in the reader the upload can be retried, the body
+	// will be created again from the start position
+	if seeker, ok := r.(io.Seeker); ok {
+		if start, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			req.GetBody = func() (io.ReadCloser, error) {
+				// Wait until the previous body stops reading
+				body.Close()
+				<-body.done
+
+				if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+					return nil, err
+				}
+
+				newBody, err := newMultipartBody(c.uploadContentReader(ctx, r, size, opts), size, filename, body.boundary)
+				if err != nil {
+					return nil, err
+				}
+
+				body = newBody
+				return body, nil
+			}
+		}
+	}
 
 	resp, err := c.session.Do(req, c.httpClient)
 
-	if err != nil || resp == nil {
-		return nil, errors.New("Error making the request")
+	if err != nil {
+		return nil, &APIError{Method: method, URL: endpoint, Err: err}
 	}
 
-	if resp.StatusCode >= 400 { // 400s and 500s
-		return resp, errors.New(fmt.Sprintf("Client response: %d", resp.StatusCode))
+	if err := checkResponse(resp); err != nil { // 400s and 500s
+		return resp, err
 	}
 
 	return resp, nil
 }
+
+// Wraps the content of an upload for limiting the bandwidth and reporting
+// the progress
+func (c *Client) uploadContentReader(ctx context.Context, r io.Reader, size int64, opts *UploadOptions) io.Reader {
+	r = c.bandwidth.Reader(ctx, r)
+
+	if opts == nil {
+		return r
+	}
+
+	r = opts.BandwidthLimiter.Reader(ctx, r)
+
+	if opts.Progress == nil {
+		return r
+	}
+
+	interval := opts.ProgressInterval
+	if interval <= 0 {
+		interval = defaultProgressInterval
+	}
+
+	return &progressReader{
+		r:        r,
+		total:    size,
+		interval: interval,
+		progress: opts.Progress,
+	}
+}
+
+// Checks the status of the response, with 400s and 500s returns an APIError.
+// The body is consumed for getting the Copy error and replaced so the caller
+// can read it again
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode < 400 {
+		return nil
+	}
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return newAPIError(resp, body)
+}
+
+// The multipart body that streams the content of a reader
+type multipartBody struct {
+	*io.PipeReader
+
+	boundary      string
+	contentType   string
+	contentLength int64
+
+	// Closed when the reader is not being read anymore
+	done chan struct{}
+}
+
+// Creates a multipart body that streams the content of the reader, the
+// returned body needs to be closed. If the size is negative the content
+// length will be unknown (-1). If the boundary is empty a random one will be
+// used.
+//
+// The key is to use a pipe
+// (reading the file will feed the writer and then the reader will be in the multipart body
+// so when the multipart request a chunk the reader will "call" the writer and this will
+// read from the file)
+//
+// File -> FileReader -> Writer -> (Multipart wrapp magic) -> Reader -> Multipart body
+//
+// From the docs: The maximum filesize of an upload is 1GB. An API endpoint
+// supporting chunked file uploading is planned for circumventing this limitation.
+func newMultipartBody(r io.Reader, size int64, filename string, boundary string) (*multipartBody, error) {
+	reader, writer := io.Pipe()
+	multiWriter := multipart.NewWriter(writer)
+
+	if boundary != "" {
+		if err := multiWriter.SetBoundary(boundary); err != nil {
+			return nil, err
+		}
+	}
+
+	// Calculate the size of the multipart envelope without the content, the
+	// final size will be the envelope and the content
+	envelope := &bytes.Buffer{}
+	envelopeWriter := multipart.NewWriter(envelope)
+	if err := envelopeWriter.SetBoundary(multiWriter.Boundary()); err != nil {
+		return nil, err
+	}
+	if _, err := envelopeWriter.CreateFormFile("file", filename); err != nil {
+		return nil, err
+	}
+	if err := envelopeWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	body := &multipartBody{
+		PipeReader:    reader,
+		boundary:      multiWriter.Boundary(),
+		contentType:   multiWriter.FormDataContentType(),
+		contentLength: -1,
+		done:          make(chan struct{}),
+	}
+
+	if size >= 0 {
+		body.contentLength = int64(envelope.Len()) + size
+	}
+
+	// The sequential write (read from file) will be in a goroutine
+	go func() {
+		defer close(body.done)
+
+		part, err := multiWriter.CreateFormFile("file", filename)
+		if err != nil {
+			writer.CloseWithError(err)
+			return
+		}
+
+		// Copy on demand, never more than the size
+		if size >= 0 {
+			r = io.LimitReader(r, size)
+		}
+		n, err := io.Copy(part, r)
+		if err == nil && size >= 0 && n != size {
+			err = fmt.Errorf("Wrong upload size, expected %d bytes, read %d", size, n)
+		}
+		if err != nil {
+			writer.CloseWithError(err)
+			return
+		}
+
+		writer.CloseWithError(multiWriter.Close())
+	}()
+
+	return body, nil
+}
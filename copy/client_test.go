@@ -2,16 +2,22 @@ package copy
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 // Global testing vars
@@ -50,13 +56,11 @@ func testMethod(t *testing.T, r *http.Request, want string) {
 
 // Creates a new Client for testing
 func NewTestClient() (*Client, error) {
-	appToken = os.Getenv(appTokenEnv)
-	appSecret = os.Getenv(appSecretEnv)
-	accessToken = os.Getenv(accessTokenEnv)
-	accessSecret = os.Getenv(accessSecretEnv)
-
 	serverUrl, _ := url.Parse(server.URL)
-	return NewClient(nil, serverUrl.String(), appToken, appSecret, accessToken, accessSecret)
+	return New(
+		WithBaseURL(serverUrl.String()),
+		WithCredentialsProvider(NewEnvCredentialsProvider()),
+	)
 }
 
 // -----------Client tests-----------------------------------------------------
@@ -339,3 +343,105 @@ func TestDoRequestMultipart(t *testing.T) {
 		t.Error(err.Error())
 	}
 }
+
+// Check a big streamed multipart request, the content length should be the
+// real one of the multipart body
+func TestDoRequestMultipartStreaming(t *testing.T) {
+
+	// Prepare the mock server
+	setup(t)
+	defer tearDown()
+
+	// Create a big file to upload
+	tmpFile, err := ioutil.TempFile("", "go-copy-multipart")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(tmpFile.Name())
+
+	origFile := bytes.Repeat([]byte("0123456789abcdef"), 1024*1024) // 16MB
+	tmpFile.Write(origFile)
+	tmpFile.Close()
+
+	mux.HandleFunc("/do-request-multipart",
+		func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, "PUT")
+
+			// Read all the body for checking the length
+			body, _ := ioutil.ReadAll(r.Body)
+			if int64(len(body)) != r.ContentLength {
+				t.Errorf("Wrong content length, got %d, want %d", r.ContentLength, len(body))
+			}
+
+			// Check that upload is ok
+			_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+			part, err := reader.NextPart()
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+
+			if part.FileName() != "big.bin" {
+				t.Errorf("Wrong filename: %s", part.FileName())
+			}
+
+			buf := new(bytes.Buffer)
+			io.Copy(buf, part)
+
+			if !bytes.Equal(origFile, buf.Bytes()) {
+				t.Errorf("contents are not equal")
+			}
+		},
+	)
+
+	_, err = client.DoRequestMultipart(tmpFile.Name(), "do-request-multipart", "big.bin", "PUT")
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	// Not existing file
+	if _, err := client.DoRequestMultipart("doesntexist.bin", "do-request-multipart", "big.bin", "PUT"); err == nil {
+		t.Errorf("Not existing file, should be an error")
+	}
+}
+
+// Check that the requests are cancelled with the context
+func TestDoRequestContext(t *testing.T) {
+
+	// Prepare the mock server
+	setup(t)
+	defer tearDown()
+
+	block := make(chan struct{})
+	defer close(block)
+
+	mux.HandleFunc("/do-request-context",
+		func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-block:
+			case <-r.Context().Done():
+			}
+		},
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := client.DoRequestDecodingContext(ctx, "GET", "do-request-context", nil, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Should be a deadline error, got: %v", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	_, err = client.DoRequestContentContext(ctx, "do-request-context", nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Should be a cancelled error, got: %v", err)
+	}
+
+	_, err = client.DoRequestMultipartReaderContext(ctx, strings.NewReader("test"), 4, "do-request-context", "test.txt", "POST")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Should be a cancelled error, got: %v", err)
+	}
+}
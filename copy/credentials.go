@@ -0,0 +1,257 @@
+package copy
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Credentials are the app and the user access oauth tokens
+type Credentials struct {
+	AppToken    AppToken
+	AccessToken AccessToken
+}
+
+// Checks if all the tokens are set
+func (c Credentials) complete() bool {
+	return c.AppToken.Token != "" && c.AppToken.Key != "" &&
+		c.AccessToken.Token != "" && c.AccessToken.Key != ""
+}
+
+// CredentialsProvider returns the credentials for creating a session. The
+// credentials are asked when the session is created and again when a
+// request gets a 401, so the providers can return rotated tokens
+type CredentialsProvider interface {
+	Credentials() (Credentials, error)
+}
+
+// Profile used by the file provider when no profile is set
+const defaultCredentialsProfile = "default"
+
+// Returns always the same credentials
+type StaticCredentialsProvider struct {
+	Creds Credentials
+}
+
+// Creates a provider with the tokens
+func NewStaticCredentialsProvider(appToken, appSecret, accessToken, accessSecret string) *StaticCredentialsProvider {
+	return &StaticCredentialsProvider{
+		Creds: Credentials{
+			AppToken:    AppToken{Token: appToken, Key: appSecret},
+			AccessToken: AccessToken{Token: accessToken, Key: accessSecret},
+		},
+	}
+}
+
+func (p *StaticCredentialsProvider) Credentials() (Credentials, error) {
+	if !p.Creds.complete() {
+		return Credentials{}, errors.New("Missing static credentials")
+	}
+	return p.Creds, nil
+}
+
+// Returns the credentials from the APP_TOKEN, APP_SECRET, ACCESS_TOKEN and
+// ACCESS_SECRET env vars
+type EnvCredentialsProvider struct{}
+
+func NewEnvCredentialsProvider() *EnvCredentialsProvider {
+	return &EnvCredentialsProvider{}
+}
+
+func (p *EnvCredentialsProvider) Credentials() (Credentials, error) {
+	creds := Credentials{
+		AppToken: AppToken{
+			Token: os.Getenv(appTokenEnv),
+			Key:   os.Getenv(appSecretEnv),
+		},
+		AccessToken: AccessToken{
+			Token: os.Getenv(accessTokenEnv),
+			Key:   os.Getenv(accessSecretEnv),
+		},
+	}
+
+	if !creds.complete() {
+		return Credentials{}, fmt.Errorf("Missing credentials env vars (%s, %s, %s, %s)",
+			appTokenEnv, appSecretEnv, accessTokenEnv, accessSecretEnv)
+	}
+
+	return creds, nil
+}
+
+// Returns the credentials of a profile from a credentials file, the file is
+// read every time the credentials are asked. If the file has .json extension
+// it will be JSON:
+//
+//	{
+//	    "default": {
+//	        "app_token": "...",
+//	        "app_secret": "...",
+//	        "access_token": "...",
+//	        "access_secret": "..."
+//	    }
+//	}
+//
+// Otherwise INI:
+//
+//	[default]
+//	app_token = ...
+//	app_secret = ...
+//	access_token = ...
+//	access_secret = ...
+type FileCredentialsProvider struct {
+	Path    string
+	Profile string
+}
+
+// Creates a file provider, if the path is empty ~/.copy/credentials will be
+// used and if the profile is empty the default profile
+func NewFileCredentialsProvider(path, profile string) *FileCredentialsProvider {
+	if path == "" {
+		path = DefaultCredentialsFile()
+	}
+
+	if profile == "" {
+		profile = defaultCredentialsProfile
+	}
+
+	return &FileCredentialsProvider{
+		Path:    path,
+		Profile: profile,
+	}
+}
+
+// Returns the default credentials file path: ~/.copy/credentials
+func DefaultCredentialsFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".copy", "credentials")
+}
+
+func (p *FileCredentialsProvider) Credentials() (Credentials, error) {
+	var profiles map[string]map[string]string
+	var err error
+
+	if strings.EqualFold(filepath.Ext(p.Path), ".json") {
+		profiles, err = readJSONCredentials(p.Path)
+	} else {
+		profiles, err = readINICredentials(p.Path)
+	}
+
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	profile := p.Profile
+	if profile == "" {
+		profile = defaultCredentialsProfile
+	}
+
+	values, ok := profiles[profile]
+	if !ok {
+		return Credentials{}, fmt.Errorf("Missing credentials profile %s in %s", profile, p.Path)
+	}
+
+	creds := Credentials{
+		AppToken: AppToken{
+			Token: values["app_token"],
+			Key:   values["app_secret"],
+		},
+		AccessToken: AccessToken{
+			Token: values["access_token"],
+			Key:   values["access_secret"],
+		},
+	}
+
+	if !creds.complete() {
+		return Credentials{}, fmt.Errorf("Missing credentials in profile %s of %s", profile, p.Path)
+	}
+
+	return creds, nil
+}
+
+func readJSONCredentials(path string) (map[string]map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	profiles := map[string]map[string]string{}
+	if err := json.NewDecoder(f).Decode(&profiles); err != nil {
+		return nil, fmt.Errorf("Wrong credentials file %s: %v", path, err)
+	}
+
+	return profiles, nil
+}
+
+func readINICredentials(path string) (map[string]map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	profiles := map[string]map[string]string{}
+	var current map[string]string
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		// Empty lines and comments
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		// New profile
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			current = map[string]string{}
+			profiles[name] = current
+			continue
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 || current == nil {
+			return nil, fmt.Errorf("Wrong credentials file %s: line %d", path, n)
+		}
+		current[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return profiles, nil
+}
+
+// Tries the providers in order and returns the first credentials found
+type ChainCredentialsProvider struct {
+	Providers []CredentialsProvider
+}
+
+func NewChainCredentialsProvider(providers ...CredentialsProvider) *ChainCredentialsProvider {
+	return &ChainCredentialsProvider{
+		Providers: providers,
+	}
+}
+
+func (p *ChainCredentialsProvider) Credentials() (Credentials, error) {
+	errs := []string{}
+
+	for _, provider := range p.Providers {
+		creds, err := provider.Credentials()
+		if err == nil {
+			return creds, nil
+		}
+		errs = append(errs, err.Error())
+	}
+
+	return Credentials{}, fmt.Errorf("No credentials found: %s", strings.Join(errs, "; "))
+}
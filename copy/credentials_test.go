@@ -0,0 +1,196 @@
+package copy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var perfectCredentials = Credentials{
+	AppToken:    AppToken{Token: "apptoken", Key: "appsecret"},
+	AccessToken: AccessToken{Token: "accesstoken", Key: "accesssecret"},
+}
+
+// Writes a credentials file in a temporal dir
+func writeCredentialsFile(t *testing.T, name, content string) string {
+	dir, err := ioutil.TempDir("", "go-copy-credentials")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+	return path
+}
+
+func TestEnvCredentialsProvider(t *testing.T) {
+	// Restore the env vars after the test
+	for _, env := range []string{appTokenEnv, appSecretEnv, accessTokenEnv, accessSecretEnv} {
+		defer os.Setenv(env, os.Getenv(env))
+	}
+
+	os.Setenv(appTokenEnv, "apptoken")
+	os.Setenv(appSecretEnv, "appsecret")
+	os.Setenv(accessTokenEnv, "accesstoken")
+	os.Setenv(accessSecretEnv, "accesssecret")
+
+	creds, err := NewEnvCredentialsProvider().Credentials()
+	if err != nil {
+		t.Fatalf("Shouldn't be an error: %v", err)
+	}
+
+	if !reflect.DeepEqual(creds, perfectCredentials) {
+		t.Errorf("Wrong credentials: %#v", creds)
+	}
+
+	os.Setenv(accessSecretEnv, "")
+	if _, err := NewEnvCredentialsProvider().Credentials(); err == nil {
+		t.Errorf("Missing env var, should be an error")
+	}
+}
+
+func TestFileCredentialsProviderINI(t *testing.T) {
+	path := writeCredentialsFile(t, "credentials", `
+# Copy credentials
+[default]
+app_token = apptoken
+app_secret = appsecret
+access_token = accesstoken
+access_secret = accesssecret
+
+; Other account
+[work]
+app_token = apptoken
+app_secret = appsecret
+access_token = workaccesstoken
+access_secret = workaccesssecret
+
+[broken]
+app_token = apptoken
+`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	creds, err := NewFileCredentialsProvider(path, "").Credentials()
+	if err != nil {
+		t.Fatalf("Shouldn't be an error: %v", err)
+	}
+
+	if !reflect.DeepEqual(creds, perfectCredentials) {
+		t.Errorf("Wrong credentials: %#v", creds)
+	}
+
+	creds, err = NewFileCredentialsProvider(path, "work").Credentials()
+	if err != nil || creds.AccessToken.Token != "workaccesstoken" || creds.AccessToken.Key != "workaccesssecret" {
+		t.Errorf("Wrong work profile credentials: %#v, %v", creds, err)
+	}
+
+	if _, err := NewFileCredentialsProvider(path, "broken").Credentials(); err == nil {
+		t.Errorf("Missing tokens in profile, should be an error")
+	}
+
+	if _, err := NewFileCredentialsProvider(path, "missing").Credentials(); err == nil {
+		t.Errorf("Missing profile, should be an error")
+	}
+
+	if _, err := NewFileCredentialsProvider(path+".missing", "").Credentials(); err == nil {
+		t.Errorf("Missing file, should be an error")
+	}
+}
+
+func TestFileCredentialsProviderJSON(t *testing.T) {
+	path := writeCredentialsFile(t, "credentials.json", `{
+        "default": {
+            "app_token": "apptoken",
+            "app_secret": "appsecret",
+            "access_token": "accesstoken",
+            "access_secret": "accesssecret"
+        }
+    }`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	creds, err := NewFileCredentialsProvider(path, "default").Credentials()
+	if err != nil {
+		t.Fatalf("Shouldn't be an error: %v", err)
+	}
+
+	if !reflect.DeepEqual(creds, perfectCredentials) {
+		t.Errorf("Wrong credentials: %#v", creds)
+	}
+}
+
+func TestChainCredentialsProvider(t *testing.T) {
+	missing := NewStaticCredentialsProvider("", "", "", "")
+	static := NewStaticCredentialsProvider("apptoken", "appsecret", "accesstoken", "accesssecret")
+
+	creds, err := NewChainCredentialsProvider(missing, static).Credentials()
+	if err != nil {
+		t.Fatalf("Shouldn't be an error: %v", err)
+	}
+
+	if !reflect.DeepEqual(creds, perfectCredentials) {
+		t.Errorf("Wrong credentials: %#v", creds)
+	}
+
+	if _, err := NewChainCredentialsProvider(missing, missing).Credentials(); err == nil {
+		t.Errorf("No provider with credentials, should be an error")
+	}
+
+	// The client and the session accept the providers
+	if _, err := NewSessionWithProvider(static); err != nil {
+		t.Errorf("Shouldn't be an error: %v", err)
+	}
+
+	if _, err := New(WithCredentialsProvider(NewChainCredentialsProvider(missing))); err == nil {
+		t.Errorf("No credentials, should be an error")
+	}
+}
+
+func TestCredentialsProviderRotation(t *testing.T) {
+	setup(t)
+	defer tearDown()
+
+	provider := NewStaticCredentialsProvider("apptoken", "appsecret", "oldtoken", "oldsecret")
+	rotated, err := New(WithBaseURL(server.URL), WithCredentialsProvider(provider))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	requests := 0
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if oauthParams(r).Get("oauth_token") != "newtoken" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":1300,"message":"Unauthorized"}`)
+			return
+		}
+		fmt.Fprint(w, `{"id":"1381231"}`)
+	})
+
+	// The same credentials aren't retried
+	if _, err := NewUserService(rotated).Get(); !IsUnauthorized(err) || requests != 1 {
+		t.Errorf("Should be unauthorized: %v, %d requests", err, requests)
+	}
+
+	// The rotated tokens are used after the 401
+	provider.Creds.AccessToken = AccessToken{Token: "newtoken", Key: "newsecret"}
+	requests = 0
+	user, err := NewUserService(rotated).Get()
+	if err != nil {
+		t.Fatalf("Shouldn't be an error: %v", err)
+	}
+	if user.Id != "1381231" || requests != 2 {
+		t.Errorf("Wrong user: %+v, %d requests", user, requests)
+	}
+
+	// The next requests use the new tokens
+	requests = 0
+	if _, err := NewUserService(rotated).Get(); err != nil || requests != 1 {
+		t.Errorf("Should use the new tokens: %v, %d requests", err, requests)
+	}
+}
@@ -0,0 +1,445 @@
+package copy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Download options
+type DownloadOptions struct {
+	// Called while the file is being downloaded
+	Progress ProgressFunc
+
+	// Don't resume the previous partial download, start again
+	NoResume bool
+
+	// Limits the bandwidth of the download (the limit of the client applies
+	// too)
+	BandwidthLimiter *BandwidthLimiter
+}
+
+// Parallel download options
+type ParallelDownloadOptions struct {
+	// Number of segments downloaded at the same time
+	Concurrency int
+
+	// Size in bytes of each segment
+	SegmentSize int64
+
+	// Attempts of each segment before failing the download (the first one
+	// included), a retry continues from the last byte written
+	SegmentAttempts int
+
+	// Wait before retrying a segment, doubled on every retry
+	SegmentBackoff time.Duration
+
+	// Called while the file is being downloaded
+	Progress ProgressFunc
+
+	// Limits the bandwidth of all the segments (the limit of the client
+	// applies too)
+	BandwidthLimiter *BandwidthLimiter
+}
+
+const (
+	// Suffix of the partial downloads
+	partialDownloadSuffix = ".part"
+
+	defaultDownloadConcurrency     = 4
+	defaultDownloadSegmentSize     = 8 * 1024 * 1024
+	defaultDownloadSegmentAttempts = 3
+	defaultDownloadSegmentBackoff  = 500 * time.Millisecond
+)
+
+// Returns the content of the file from the byte start to the byte end (both
+// included), a negative end means until the end of the file. If Copy ignores
+// the range the bytes before start are discarded
+func (fs *FileService) GetFileRange(ctx context.Context, path string, start, end int64) (io.ReadCloser, error) {
+	path = strings.Trim(path, "/")
+
+	resp, err := fs.client.DoRequestContentRangeContext(ctx, strings.Join([]string{filesTopLevelSuffix, path}, "/"), nil, start, end)
+	if err != nil {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusPartialContent {
+		return resp.Body, nil
+	}
+
+	// All the file, skip to the range
+	if _, err := io.CopyN(ioutil.Discard, resp.Body, start); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	if end < 0 {
+		return resp.Body, nil
+	}
+
+	return &limitedReadCloser{
+		Reader: io.LimitReader(resp.Body, end-start+1),
+		Closer: resp.Body,
+	}, nil
+}
+
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// Downloads the remote file to the local path. The file is downloaded first
+// to localPath.part, if the download fails, calling again will resume the
+// download from where it stopped (with HTTP Range requests). When the size
+// of the download is the size of the remote file the partial file is
+// renamed to the local path
+func (fs *FileService) DownloadToFile(ctx context.Context, remotePath, localPath string, opts *DownloadOptions) error {
+	if opts == nil {
+		opts = &DownloadOptions{}
+	}
+
+	remotePath = strings.Trim(remotePath, "/")
+
+	meta, err := fs.GetMetaContext(ctx, remotePath)
+	if err != nil {
+		return err
+	}
+
+	if meta.IsDir() {
+		return fmt.Errorf("Can't download %s, is a directory", remotePath)
+	}
+
+	total := int64(meta.Size)
+	partPath := localPath + partialDownloadSuffix
+
+	flags := os.O_CREATE | os.O_WRONLY
+	if opts.NoResume {
+		flags |= os.O_TRUNC
+	}
+
+	file, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	// The partial file isn't from this file, start again
+	if offset > total {
+		if err := file.Truncate(0); err != nil {
+			return err
+		}
+		offset = 0
+	}
+
+	if offset < total {
+		if err := fs.downloadRange(ctx, remotePath, file, offset, total, opts); err != nil {
+			return err
+		}
+	} else if opts.Progress != nil {
+		opts.Progress(total, total)
+	}
+
+	// Check that we have all the file
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	if info.Size() != total {
+		return fmt.Errorf("Wrong download size of %s, expected %d bytes, got %d", remotePath, total, info.Size())
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(partPath, localPath)
+}
+
+// Downloads the file from the offset to the end and writes it in the file
+// (at the offset)
+func (fs *FileService) downloadRange(ctx context.Context, remotePath string, file *os.File, offset, total int64, opts *DownloadOptions) error {
+	resp, err := fs.client.DoRequestContentRangeContext(ctx, strings.Join([]string{filesTopLevelSuffix, remotePath}, "/"), nil, offset, -1)
+	if err != nil {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	// The server ignored the range and sends all the file
+	if resp.StatusCode != http.StatusPartialContent && offset > 0 {
+		if err := file.Truncate(0); err != nil {
+			return err
+		}
+		offset = 0
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	var w io.Writer = file
+	if opts.Progress != nil {
+		opts.Progress(offset, total)
+		w = &progressWriter{w: file, done: offset, total: total, progress: opts.Progress}
+	}
+
+	_, err = io.Copy(w, opts.BandwidthLimiter.Reader(ctx, resp.Body))
+	return err
+}
+
+// Downloads the remote file to the local path in segments of bytes that are
+// downloaded concurrently (with HTTP Range requests) and written in their
+// place of the file. The file is downloaded to localPath.part and renamed
+// to the local path when all the segments are downloaded. Unlike
+// DownloadToFile the parallel downloads are not resumed
+func (fs *FileService) DownloadParallel(ctx context.Context, remotePath, localPath string, opts *ParallelDownloadOptions) error {
+	o := ParallelDownloadOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = defaultDownloadConcurrency
+	}
+	if o.SegmentSize <= 0 {
+		o.SegmentSize = defaultDownloadSegmentSize
+	}
+	if o.SegmentAttempts <= 0 {
+		o.SegmentAttempts = defaultDownloadSegmentAttempts
+	}
+	if o.SegmentBackoff <= 0 {
+		o.SegmentBackoff = defaultDownloadSegmentBackoff
+	}
+
+	remotePath = strings.Trim(remotePath, "/")
+
+	meta, err := fs.GetMetaContext(ctx, remotePath)
+	if err != nil {
+		return err
+	}
+
+	if meta.IsDir() {
+		return fmt.Errorf("Can't download %s, is a directory", remotePath)
+	}
+
+	total := int64(meta.Size)
+	partPath := localPath + partialDownloadSuffix
+
+	file, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Preallocate the file, the segments are written at their offsets
+	if err := file.Truncate(total); err != nil {
+		return err
+	}
+
+	progress := &downloadProgress{total: total, progress: o.Progress}
+	progress.add(0)
+
+	// The first failed segment stops the others
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	segments := make(chan downloadSegment)
+	errs := make(chan error, o.Concurrency)
+	var wg sync.WaitGroup
+
+	for i := 0; i < o.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for seg := range segments {
+				if err := fs.downloadSegment(ctx, remotePath, file, seg, &o, progress); err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+
+feed:
+	for start := int64(0); start < total; start += o.SegmentSize {
+		end := start + o.SegmentSize - 1
+		if end >= total {
+			end = total - 1
+		}
+
+		select {
+		case segments <- downloadSegment{start: start, end: end}:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(segments)
+	wg.Wait()
+
+	select {
+	case err := <-errs:
+		return err
+	default:
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(partPath, localPath)
+}
+
+// The bytes [start, end] of a file
+type downloadSegment struct {
+	start int64
+	end   int64
+}
+
+// Downloads the segment of the file retrying the failed attempts from the
+// last byte written
+func (fs *FileService) downloadSegment(ctx context.Context, remotePath string, file io.WriterAt, seg downloadSegment, opts *ParallelDownloadOptions, progress *downloadProgress) error {
+	w := &segmentWriter{w: file, offset: seg.start, progress: progress}
+	length := seg.end - seg.start + 1
+	backoff := opts.SegmentBackoff
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fs.downloadSegmentAttempt(ctx, remotePath, w, seg.end, length-(w.offset-seg.start), opts.BandwidthLimiter)
+		if err == nil || !retryableSegmentError(ctx, err) || attempt >= opts.SegmentAttempts {
+			break
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+
+	if err != nil {
+		return fmt.Errorf("Could not download bytes %d-%d of %s: %w", seg.start, seg.end, remotePath, err)
+	}
+
+	return nil
+}
+
+// Downloads the remaining bytes of a segment, from the offset of the writer
+// to the end of the segment
+func (fs *FileService) downloadSegmentAttempt(ctx context.Context, remotePath string, w *segmentWriter, end, remaining int64, limiter *BandwidthLimiter) error {
+	resp, err := fs.client.DoRequestContentRangeContext(ctx, strings.Join([]string{filesTopLevelSuffix, remotePath}, "/"), nil, w.offset, end)
+	if err != nil {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Writing the whole file in each segment would break it
+	if resp.StatusCode != http.StatusPartialContent {
+		return errRangeNotSupported
+	}
+
+	n, err := io.Copy(w, io.LimitReader(limiter.Reader(ctx, resp.Body), remaining))
+	if err != nil {
+		return err
+	}
+
+	if n < remaining {
+		return io.ErrUnexpectedEOF
+	}
+
+	return nil
+}
+
+var errRangeNotSupported = errors.New("Range requests not supported by the server")
+
+// Checks if a segment should be retried after the error, the client errors
+// (bad path, unauthorized...) will fail again
+func retryableSegmentError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || err == errRangeNotSupported {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode >= 400 && apiErr.StatusCode < 500 &&
+		apiErr.StatusCode != http.StatusTooManyRequests {
+		return false
+	}
+
+	return true
+}
+
+// Writes sequentially at the offset of the file and reports the progress
+type segmentWriter struct {
+	w        io.WriterAt
+	offset   int64
+	progress *downloadProgress
+}
+
+func (s *segmentWriter) Write(b []byte) (int, error) {
+	n, err := s.w.WriteAt(b, s.offset)
+	s.offset += int64(n)
+	s.progress.add(int64(n))
+	return n, err
+}
+
+// The progress of the concurrent segments, the progress function is called
+// from one segment at a time
+type downloadProgress struct {
+	mu       sync.Mutex
+	done     int64
+	total    int64
+	progress ProgressFunc
+}
+
+func (p *downloadProgress) add(n int64) {
+	if p.progress == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done += n
+	p.progress(p.done, p.total)
+}
+
+// Calls the progress function on every write
+type progressWriter struct {
+	w        io.Writer
+	done     int64
+	total    int64
+	progress ProgressFunc
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.done += int64(n)
+	p.progress(p.done, p.total)
+	return n, err
+}
@@ -0,0 +1,362 @@
+package copy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var downloadContent = bytes.Repeat([]byte("0123456789abcdef"), 64*1024) // 1MB
+
+// Setups a remote file that supports range requests, returns the pointer
+// to the number of bytes served
+func setupDownload(t *testing.T, size int) *int64 {
+	setupFileService(t)
+
+	served := new(int64)
+
+	mux.HandleFunc("/"+fmt.Sprintf(getMetaSuffix, "big.bin"),
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"path":"/big.bin", "name":"big.bin", "type":"file", "size":%d}`, size)
+		},
+	)
+
+	mux.HandleFunc(strings.Join([]string{"", filesTopLevelSuffix, "big.bin"}, "/"),
+		func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, "GET")
+			cw := &countingResponseWriter{ResponseWriter: w, count: served}
+			http.ServeContent(cw, r, "big.bin", time.Time{}, bytes.NewReader(downloadContent))
+		},
+	)
+
+	return served
+}
+
+// Counts the bytes of the body
+type countingResponseWriter struct {
+	http.ResponseWriter
+	count *int64
+}
+
+func (w *countingResponseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	atomic.AddInt64(w.count, int64(n))
+	return n, err
+}
+
+// Creates a temporal dir for the downloads
+func downloadDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "go-copy-download")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return dir
+}
+
+func TestDownloadToFile(t *testing.T) {
+	setupDownload(t, len(downloadContent))
+	defer tearDownFileService()
+
+	dir := downloadDir(t)
+	defer os.RemoveAll(dir)
+	localPath := filepath.Join(dir, "big.bin")
+
+	var lastDone, lastTotal int64
+	opts := &DownloadOptions{
+		Progress: func(done, total int64) {
+			if done < lastDone {
+				t.Errorf("Progress should only grow")
+			}
+			lastDone, lastTotal = done, total
+		},
+	}
+
+	if err := fileService.DownloadToFile(context.Background(), "big.bin", localPath, opts); err != nil {
+		t.Fatalf("Shouldn't be an error: %v", err)
+	}
+
+	content, _ := ioutil.ReadFile(localPath)
+	if !bytes.Equal(content, downloadContent) {
+		t.Errorf("contents are not equal")
+	}
+
+	if lastDone != int64(len(downloadContent)) || lastTotal != int64(len(downloadContent)) {
+		t.Errorf("Wrong final progress: %d/%d", lastDone, lastTotal)
+	}
+
+	if _, err := os.Stat(localPath + partialDownloadSuffix); !os.IsNotExist(err) {
+		t.Errorf("Partial file should be removed")
+	}
+}
+
+func TestDownloadToFileResume(t *testing.T) {
+	served := setupDownload(t, len(downloadContent))
+	defer tearDownFileService()
+
+	dir := downloadDir(t)
+	defer os.RemoveAll(dir)
+	localPath := filepath.Join(dir, "big.bin")
+
+	// The previous download stopped at 90%
+	partial := len(downloadContent) * 9 / 10
+	ioutil.WriteFile(localPath+partialDownloadSuffix, downloadContent[:partial], 0644)
+
+	if err := fileService.DownloadToFile(context.Background(), "big.bin", localPath, nil); err != nil {
+		t.Fatalf("Shouldn't be an error: %v", err)
+	}
+
+	content, _ := ioutil.ReadFile(localPath)
+	if !bytes.Equal(content, downloadContent) {
+		t.Errorf("contents are not equal")
+	}
+
+	if atomic.LoadInt64(served) != int64(len(downloadContent)-partial) {
+		t.Errorf("Only the rest of the file should be downloaded, served: %d", atomic.LoadInt64(served))
+	}
+
+	// Without resuming
+	atomic.StoreInt64(served, 0)
+	ioutil.WriteFile(localPath+partialDownloadSuffix, downloadContent[:partial], 0644)
+
+	if err := fileService.DownloadToFile(context.Background(), "big.bin", localPath, &DownloadOptions{NoResume: true}); err != nil {
+		t.Fatalf("Shouldn't be an error: %v", err)
+	}
+
+	if atomic.LoadInt64(served) != int64(len(downloadContent)) {
+		t.Errorf("All the file should be downloaded, served: %d", atomic.LoadInt64(served))
+	}
+}
+
+func TestDownloadToFileWrongSize(t *testing.T) {
+	// The meta says that the file is bigger
+	setupDownload(t, len(downloadContent)+10)
+	defer tearDownFileService()
+
+	dir := downloadDir(t)
+	defer os.RemoveAll(dir)
+	localPath := filepath.Join(dir, "big.bin")
+
+	if err := fileService.DownloadToFile(context.Background(), "big.bin", localPath, nil); err == nil {
+		t.Errorf("Wrong size, should be an error")
+	}
+
+	if _, err := os.Stat(localPath); !os.IsNotExist(err) {
+		t.Errorf("Wrong downloads shouldn't be renamed")
+	}
+
+	// Missing file
+	if err := fileService.DownloadToFile(context.Background(), "doesntexist.bin", localPath, nil); !IsNotFound(err) {
+		t.Errorf("Should be a not found error: %v", err)
+	}
+}
+
+func TestDownloadParallel(t *testing.T) {
+	setupDownload(t, len(downloadContent))
+	defer tearDownFileService()
+
+	dir := downloadDir(t)
+	defer os.RemoveAll(dir)
+	localPath := filepath.Join(dir, "big.bin")
+
+	var lastDone int64
+	opts := &ParallelDownloadOptions{
+		Concurrency: 3,
+		SegmentSize: 100 * 1024,
+		Progress: func(done, total int64) {
+			if done < lastDone {
+				t.Errorf("Progress should only grow")
+			}
+			lastDone = done
+		},
+	}
+
+	if err := fileService.DownloadParallel(context.Background(), "big.bin", localPath, opts); err != nil {
+		t.Fatalf("Shouldn't be an error: %v", err)
+	}
+
+	content, _ := ioutil.ReadFile(localPath)
+	if !bytes.Equal(content, downloadContent) {
+		t.Errorf("contents are not equal")
+	}
+
+	if lastDone != int64(len(downloadContent)) {
+		t.Errorf("Wrong final progress: %d", lastDone)
+	}
+}
+
+// Aborts the connection after writing some bytes
+type abortingResponseWriter struct {
+	http.ResponseWriter
+	left int
+}
+
+func (w *abortingResponseWriter) Write(b []byte) (int, error) {
+	if len(b) > w.left {
+		w.ResponseWriter.Write(b[:w.left])
+		w.ResponseWriter.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}
+	w.left -= len(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func TestDownloadParallelSegmentRetry(t *testing.T) {
+	setupFileService(t)
+	defer tearDownFileService()
+
+	mux.HandleFunc("/"+fmt.Sprintf(getMetaSuffix, "big.bin"),
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"path":"/big.bin", "name":"big.bin", "type":"file", "size":%d}`, len(downloadContent))
+		},
+	)
+
+	// The first attempt of each segment fails in the middle
+	var mu sync.Mutex
+	failed := map[string]bool{}
+	var requests int64
+
+	mux.HandleFunc(strings.Join([]string{"", filesTopLevelSuffix, "big.bin"}, "/"),
+		func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt64(&requests, 1)
+
+			// The retries have the same end of the range
+			end := r.Header.Get("Range")[strings.Index(r.Header.Get("Range"), "-"):]
+
+			mu.Lock()
+			fail := !failed[end]
+			failed[end] = true
+			mu.Unlock()
+
+			if fail {
+				w = &abortingResponseWriter{ResponseWriter: w, left: 1000}
+			}
+			http.ServeContent(w, r, "big.bin", time.Time{}, bytes.NewReader(downloadContent))
+		},
+	)
+
+	dir := downloadDir(t)
+	defer os.RemoveAll(dir)
+	localPath := filepath.Join(dir, "big.bin")
+
+	opts := &ParallelDownloadOptions{
+		Concurrency:    4,
+		SegmentSize:    256 * 1024,
+		SegmentBackoff: time.Millisecond,
+	}
+
+	if err := fileService.DownloadParallel(context.Background(), "big.bin", localPath, opts); err != nil {
+		t.Fatalf("Shouldn't be an error: %v", err)
+	}
+
+	content, _ := ioutil.ReadFile(localPath)
+	if !bytes.Equal(content, downloadContent) {
+		t.Errorf("contents are not equal")
+	}
+
+	// 4 segments and the retries (from the byte 1000 of the segment)
+	if n := atomic.LoadInt64(&requests); n != 8 {
+		t.Errorf("Wrong number of requests: %d", n)
+	}
+}
+
+func TestDownloadParallelErrors(t *testing.T) {
+	setupFileService(t)
+	defer tearDownFileService()
+
+	mux.HandleFunc("/"+fmt.Sprintf(getMetaSuffix, "big.bin"),
+		func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"path":"/big.bin", "name":"big.bin", "type":"file", "size":%d}`, len(downloadContent))
+		},
+	)
+
+	// Ignores the ranges
+	mux.HandleFunc(strings.Join([]string{"", filesTopLevelSuffix, "big.bin"}, "/"),
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write(downloadContent)
+		},
+	)
+
+	dir := downloadDir(t)
+	defer os.RemoveAll(dir)
+	localPath := filepath.Join(dir, "big.bin")
+
+	opts := &ParallelDownloadOptions{SegmentSize: 256 * 1024, SegmentBackoff: time.Millisecond}
+
+	err := fileService.DownloadParallel(context.Background(), "big.bin", localPath, opts)
+	if err == nil || !strings.Contains(err.Error(), "Range requests not supported") {
+		t.Errorf("Should be a range error: %v", err)
+	}
+
+	if _, err := os.Stat(localPath); !os.IsNotExist(err) {
+		t.Errorf("Wrong downloads shouldn't be renamed")
+	}
+
+	// Client errors are not retried
+	if err := fileService.DownloadParallel(context.Background(), "doesntexist.bin", localPath, opts); !IsNotFound(err) {
+		t.Errorf("Should be a not found error: %v", err)
+	}
+}
+
+func TestGetFileRange(t *testing.T) {
+	setupDownload(t, len(downloadContent))
+	defer tearDownFileService()
+
+	tests := []struct {
+		start, end int64
+		expected   []byte
+	}{
+		{0, 9, downloadContent[:10]},
+		{100, -1, downloadContent[100:]},
+		{16, 31, downloadContent[16:32]},
+	}
+
+	for _, test := range tests {
+		r, err := fileService.GetFileRange(context.Background(), "/big.bin", test.start, test.end)
+		if err != nil {
+			t.Fatalf("Shouldn't be an error: %v", err)
+		}
+
+		content, _ := ioutil.ReadAll(r)
+		r.Close()
+
+		if !bytes.Equal(content, test.expected) {
+			t.Errorf("Wrong content of range %d-%d", test.start, test.end)
+		}
+	}
+
+	if _, err := fileService.GetFileRange(context.Background(), "doesntexist.bin", 0, 10); !IsNotFound(err) {
+		t.Errorf("Should be a not found error: %v", err)
+	}
+}
+
+func TestGetFileRangeIgnored(t *testing.T) {
+	setupFileService(t)
+	defer tearDownFileService()
+
+	// Ignores the ranges
+	mux.HandleFunc(strings.Join([]string{"", filesTopLevelSuffix, "big.bin"}, "/"),
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write(downloadContent)
+		},
+	)
+
+	r, err := fileService.GetFileRange(context.Background(), "big.bin", 16, 31)
+	if err != nil {
+		t.Fatalf("Shouldn't be an error: %v", err)
+	}
+	defer r.Close()
+
+	content, _ := ioutil.ReadAll(r)
+	if !bytes.Equal(content, downloadContent[16:32]) {
+		t.Errorf("Wrong content: %q", content)
+	}
+}
@@ -0,0 +1,117 @@
+package copy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Sentinel errors for checking the API errors with errors.Is, for example:
+//
+//	if errors.Is(err, copy.ErrNotFound) {...}
+var (
+	ErrNotFound      = errors.New("Not found")
+	ErrUnauthorized  = errors.New("Unauthorized")
+	ErrConflict      = errors.New("Conflict")
+	ErrQuotaExceeded = errors.New("Quota exceeded")
+)
+
+// APIError is returned by the client when a request fails, it could be
+// because the Copy API responded with an error status (StatusCode will be
+// set) or because the request couldn't be made (Err will be set)
+type APIError struct {
+	// The HTTP status code of the response, 0 if there wasn't a response
+	StatusCode int
+
+	// The error code and message that Copy returns in the response body
+	Code    int
+	Message string
+
+	// The request that failed
+	Method string
+	URL    string
+
+	// The transport error if the request couldn't be made
+	Err error
+}
+
+// The error body that Copy returns with the 400s and 500s
+type apiErrorBody struct {
+	Code    int    `json:"error,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Creates an APIError from an error response, the body will be decoded to
+// get the Copy error (if present)
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+	}
+
+	if resp.Request != nil {
+		apiErr.Method = resp.Request.Method
+		apiErr.URL = resp.Request.URL.String()
+	}
+
+	errBody := apiErrorBody{}
+	if json.Unmarshal(body, &errBody) == nil {
+		apiErr.Code = errBody.Code
+		apiErr.Message = errBody.Message
+	}
+
+	return apiErr
+}
+
+func (e *APIError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("Error making the request (%s %s): %v", e.Method, e.URL, e.Err)
+	}
+
+	msg := fmt.Sprintf("Client response: %d (%s %s)", e.StatusCode, e.Method, e.URL)
+	if e.Code != 0 || e.Message != "" {
+		msg = fmt.Sprintf("%s: Copy error %d: %s", msg, e.Code, e.Message)
+	}
+
+	return msg
+}
+
+// Returns the transport error (if any)
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Matches the API error with the sentinel errors
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrQuotaExceeded:
+		return e.StatusCode == http.StatusInsufficientStorage
+	}
+	return false
+}
+
+// Checks if the error is a not found error from the API
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// Checks if the error is an unauthorized error from the API
+func IsUnauthorized(err error) bool {
+	return errors.Is(err, ErrUnauthorized)
+}
+
+// Checks if the error is a conflict (already exists) error from the API
+func IsConflict(err error) bool {
+	return errors.Is(err, ErrConflict)
+}
+
+// Checks if the error is a quota exceeded error from the API
+func IsQuotaExceeded(err error) bool {
+	return errors.Is(err, ErrQuotaExceeded)
+}
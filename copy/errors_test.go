@@ -0,0 +1,96 @@
+package copy
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// Checks the API error decoding from the responses
+func TestAPIError(t *testing.T) {
+	setup(t)
+	defer tearDown()
+
+	mux.HandleFunc("/api-error",
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": 1301, "message": "Object not found"}`)
+		},
+	)
+
+	_, err := client.DoRequestDecoding("GET", "api-error", nil, nil)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Should be an APIError: %v", err)
+	}
+
+	if apiErr.StatusCode != http.StatusNotFound || apiErr.Code != 1301 ||
+		apiErr.Message != "Object not found" || apiErr.Method != "GET" ||
+		!strings.HasSuffix(apiErr.URL, "/api-error") {
+		t.Errorf("Wrong API error: %#v", apiErr)
+	}
+
+	if !IsNotFound(err) || !errors.Is(err, ErrNotFound) {
+		t.Errorf("Should be a not found error")
+	}
+
+	if IsUnauthorized(err) || IsConflict(err) || IsQuotaExceeded(err) {
+		t.Errorf("Should be only a not found error")
+	}
+
+	// Content requests should have the error and the body again
+	resp, err := client.DoRequestContent("api-error", nil)
+
+	if !IsNotFound(err) {
+		t.Errorf("Should be a not found error")
+	}
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "Object not found") {
+		t.Errorf("Body should be readable after the error")
+	}
+
+	// Transport errors
+	server.Close()
+	_, err = client.DoRequestDecoding("GET", "api-error", nil, nil)
+
+	if !errors.As(err, &apiErr) || apiErr.Err == nil || apiErr.StatusCode != 0 {
+		t.Errorf("Should be an APIError with the transport error: %v", err)
+	}
+
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		t.Errorf("Transport error should be wrapped: %v", err)
+	}
+}
+
+// Checks the sentinel errors
+func TestAPIErrorIs(t *testing.T) {
+	tests := []struct {
+		status   int
+		sentinel error
+		check    func(error) bool
+	}{
+		{http.StatusNotFound, ErrNotFound, IsNotFound},
+		{http.StatusUnauthorized, ErrUnauthorized, IsUnauthorized},
+		{http.StatusConflict, ErrConflict, IsConflict},
+		{http.StatusInsufficientStorage, ErrQuotaExceeded, IsQuotaExceeded},
+	}
+
+	for _, test := range tests {
+		err := fmt.Errorf("wrapped: %w", &APIError{StatusCode: test.status})
+
+		if !errors.Is(err, test.sentinel) || !test.check(err) {
+			t.Errorf("%d should be %v", test.status, test.sentinel)
+		}
+
+		if test.check(errors.New("other error")) {
+			t.Errorf("Other errors shouldn't be %v", test.sentinel)
+		}
+	}
+}
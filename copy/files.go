@@ -1,11 +1,14 @@
 package copy
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 // File Meta data representation
@@ -18,9 +21,9 @@ type Meta struct {
 	Permissions             string                  `json:"permissions,omitempty"`
 	Public                  bool                    `json:"public,omitempty"`
 	Type                    string                  `json:"type,omitempty"`
-	Size                    int                     `json:"size,omitempty"`
-	DateLastSynced          int                     `json:"date_last_synced,omitempty"`
-	ModifiedTime            int                     `json:"modified_time,omitempty"`
+	Size                    Size                    `json:"size,omitempty"`
+	DateLastSynced          Timestamp               `json:"date_last_synced,omitempty"`
+	ModifiedTime            Timestamp               `json:"modified_time,omitempty"`
 	Stub                    bool                    `json:"stub,omitempty"`
 	Share                   bool                    `json:"share,omitempty"`
 	Children                []Meta                  `json:"children,omitempty"` // Inception :D
@@ -48,6 +51,8 @@ type Count struct {
 
 type Link struct {
 	Id                   string      `json:"id,omitempty"`
+	Name                 string      `json:"name,omitempty"`
+	Permissions          string      `json:"permissions,omitempty"`
 	Public               bool        `json:"public,omitempty"`
 	Expires              bool        `json:"expires,omitempty"`
 	Expired              bool        `json:"expired,omitempty"`
@@ -75,23 +80,23 @@ type ThumbOriginalDimensions struct {
 }
 
 type Revision struct {
-	RevisionId   string  `json:"revision_id,omitempty"`
-	ModifiedTime string  `json:"modified_time,omitempty"`
-	Size         int     `json:"size,omitempty"`
-	Latest       bool    `json:"latest,omitempty"`
-	Conflict     int     `json:"conflict,omitempty"`
-	Id           string  `json:"id,omitempty"`
-	Type         string  `json:"type,omitempty"`
-	Creator      Creator `json:"creator,omitempty"`
+	RevisionId   string    `json:"revision_id,omitempty"`
+	ModifiedTime Timestamp `json:"modified_time,omitempty"`
+	Size         Size      `json:"size,omitempty"`
+	Latest       bool      `json:"latest,omitempty"`
+	Conflict     int       `json:"conflict,omitempty"`
+	Id           string    `json:"id,omitempty"`
+	Type         string    `json:"type,omitempty"`
+	Creator      Creator   `json:"creator,omitempty"`
 }
 
 type Creator struct {
-	UserId      string `json:"user_id,omitempty"`
-	CreatedTime int    `json:"created_time,omitempty"`
-	Email       string `json:"email,omitempty"`
-	FirstName   string `json:"first_name,omitempty"`
-	LastName    string `json:"last_name,omitempty"`
-	Confirmed   bool   `json:"confirmed,omitempty"`
+	UserId      string    `json:"user_id,omitempty"`
+	CreatedTime Timestamp `json:"created_time,omitempty"`
+	Email       string    `json:"email,omitempty"`
+	FirstName   string    `json:"first_name,omitempty"`
+	LastName    string    `json:"last_name,omitempty"`
+	Confirmed   bool      `json:"confirmed,omitempty"`
 }
 
 type FileService struct {
@@ -117,6 +122,7 @@ var (
 	filesCreateSuffix   = strings.Join([]string{filesTopLevelSuffix, "/%v?", overwriteOption}, "")                  // http.../files/PATH?overwrite=FLAG
 	filesRenameSuffix   = strings.Join([]string{filesTopLevelSuffix, "/%v?", nameOption, "&", overwriteOption}, "") // http.../files/PATH?name=NEWFILENAME&overwrite=FLAG
 	filesMoveSuffix     = strings.Join([]string{filesTopLevelSuffix, "/%v?", pathOption, "&", overwriteOption}, "") // http.../files/PATH?overwrite=FLAG
+	filesRevisionSuffix = strings.Join([]string{filesTopLevelSuffix, "/%v/@activity/@time:%d"}, "")                 // http.../files/PATH/@activity/@time:TIME
 
 	thumbsTopLevelSuffix = "thumbs"
 	//filesThumbnailSuffix = strings.Join([]string{thumbsTopLevelSuffix, "/%v?", sizeOption}, "") // http.../thumbs/PATH?size=SIZE
@@ -132,8 +138,13 @@ func NewFileService(client *Client) *FileService {
 //
 // https://www.copy.com/developer/documentation#api-calls/filesystem
 func (fs *FileService) GetTopLevelMeta() (*Meta, error) {
+	return fs.GetTopLevelMetaContext(context.Background())
+}
+
+// Like GetTopLevelMeta but the request is made with the context
+func (fs *FileService) GetTopLevelMetaContext(ctx context.Context) (*Meta, error) {
 	meta := new(Meta)
-	_, err := fs.client.DoRequestDecoding("GET", metaTopLevelSuffix, nil, meta)
+	_, err := fs.client.DoRequestDecodingContext(ctx, "GET", metaTopLevelSuffix, nil, meta)
 
 	if err != nil {
 		return nil, err
@@ -146,11 +157,16 @@ func (fs *FileService) GetTopLevelMeta() (*Meta, error) {
 //
 // https://www.copy.com/developer/documentation#api-calls/filesystem
 func (fs *FileService) GetMeta(path string) (*Meta, error) {
+	return fs.GetMetaContext(context.Background(), path)
+}
+
+// Like GetMeta but the request is made with the context
+func (fs *FileService) GetMetaContext(ctx context.Context, path string) (*Meta, error) {
 
 	path = strings.Trim(path, "/")
 
 	meta := new(Meta)
-	_, err := fs.client.DoRequestDecoding("GET", fmt.Sprintf(getMetaSuffix, path), nil, meta)
+	_, err := fs.client.DoRequestDecodingContext(ctx, "GET", fmt.Sprintf(getMetaSuffix, path), nil, meta)
 
 	if err != nil {
 		return nil, err
@@ -163,8 +179,13 @@ func (fs *FileService) GetMeta(path string) (*Meta, error) {
 //
 // https://www.copy.com/developer/documentation#api-calls/filesystem
 func (fs *FileService) ListRevisionsMeta(path string) ([]Revision, error) {
+	return fs.ListRevisionsMetaContext(context.Background(), path)
+}
+
+// Like ListRevisionsMeta but the request is made with the context
+func (fs *FileService) ListRevisionsMetaContext(ctx context.Context, path string) ([]Revision, error) {
 	meta := new(Meta)
-	_, err := fs.client.DoRequestDecoding("GET", fmt.Sprintf(listRevisionsSuffix, path), nil, meta)
+	_, err := fs.client.DoRequestDecodingContext(ctx, "GET", fmt.Sprintf(listRevisionsSuffix, path), nil, meta)
 
 	if err != nil {
 		return nil, err
@@ -177,8 +198,13 @@ func (fs *FileService) ListRevisionsMeta(path string) ([]Revision, error) {
 //
 // https://www.copy.com/developer/documentation#api-calls/filesystem
 func (fs *FileService) GetRevisionMeta(path string, time int) (*Meta, error) {
+	return fs.GetRevisionMetaContext(context.Background(), path, time)
+}
+
+// Like GetRevisionMeta but the request is made with the context
+func (fs *FileService) GetRevisionMetaContext(ctx context.Context, path string, time int) (*Meta, error) {
 	meta := new(Meta)
-	_, err := fs.client.DoRequestDecoding("GET", fmt.Sprintf(revisionSuffix, path, time), nil, meta)
+	_, err := fs.client.DoRequestDecodingContext(ctx, "GET", fmt.Sprintf(revisionSuffix, path, time), nil, meta)
 
 	if err != nil {
 		return nil, err
@@ -191,8 +217,13 @@ func (fs *FileService) GetRevisionMeta(path string, time int) (*Meta, error) {
 //
 // https://www.copy.com/developer/documentation#api-calls/filesystem
 func (fs *FileService) GetFile(path string) (io.ReadCloser, error) {
+	return fs.GetFileContext(context.Background(), path)
+}
 
-	resp, err := fs.client.DoRequestContent(strings.Join([]string{filesTopLevelSuffix, path}, "/"), nil)
+// Like GetFile but the request is made with the context
+func (fs *FileService) GetFileContext(ctx context.Context, path string) (io.ReadCloser, error) {
+
+	resp, err := fs.client.DoRequestContentContext(ctx, strings.Join([]string{filesTopLevelSuffix, path}, "/"), nil)
 
 	if err != nil {
 		return nil, err
@@ -205,9 +236,14 @@ func (fs *FileService) GetFile(path string) (io.ReadCloser, error) {
 //
 // https://www.copy.com/developer/documentation#api-calls/filesystem
 func (fs *FileService) DeleteFile(path string) error {
+	return fs.DeleteFileContext(context.Background(), path)
+}
+
+// Like DeleteFile but the request is made with the context
+func (fs *FileService) DeleteFileContext(ctx context.Context, path string) error {
 	path = strings.Trim(path, "/")
 
-	_, err := fs.client.DoRequestDecoding("DELETE", strings.Join([]string{filesTopLevelSuffix, path}, "/"), nil, nil)
+	_, err := fs.client.DoRequestDecodingContext(ctx, "DELETE", strings.Join([]string{filesTopLevelSuffix, path}, "/"), nil, nil)
 
 	if err != nil {
 		return err
@@ -216,6 +252,22 @@ func (fs *FileService) DeleteFile(path string) error {
 	return nil
 }
 
+// Upload options for the reader uploads
+type UploadOptions struct {
+	// Overwrite the remote file if already exists (only when creating files)
+	Overwrite bool
+
+	// Called while the content is being uploaded
+	Progress ProgressFunc
+
+	// Minimum time between the progress calls (100ms by default)
+	ProgressInterval time.Duration
+
+	// Limits the bandwidth of the upload (the limit of the client applies
+	// too)
+	BandwidthLimiter *BandwidthLimiter
+}
+
 // Uploads the file. Loads the file from the file path and uploads to the
 // uploadPath.
 // For example:
@@ -224,31 +276,54 @@ func (fs *FileService) DeleteFile(path string) error {
 //
 // https://www.copy.com/developer/documentation#api-calls/filesystem
 func (fs *FileService) UploadFile(filePath, uploadPath string, overwrite bool) error {
+	return fs.UploadFileContext(context.Background(), filePath, uploadPath, overwrite)
+}
 
-	// Sanitize path
-	uploadPath = strings.Trim(uploadPath, "/")
+// Like UploadFile but the request is made with the context
+func (fs *FileService) UploadFileContext(ctx context.Context, filePath, uploadPath string, overwrite bool) error {
+	return fs.UploadFileWithOptions(ctx, filePath, uploadPath, &UploadOptions{Overwrite: overwrite})
+}
 
-	// Get upload filename
-	filename := filepath.Base(uploadPath)
+// Like UploadFile but with the upload options (progress...)
+func (fs *FileService) UploadFileWithOptions(ctx context.Context, filePath, uploadPath string, opts *UploadOptions) error {
 
-	if filename == "" {
-		return errors.New("Wrong uploadPath")
+	file, size, err := openUploadFile(filePath)
+
+	if err != nil {
+		return err
 	}
+	defer file.Close()
 
-	// Get upload path
-	uploadPath = filepath.Dir(uploadPath)
+	return fs.UploadReaderContext(ctx, file, size, uploadPath, opts)
+}
 
-	if uploadPath == "." { // Check if is at root, if so delete the point returned by Dir
-		uploadPath = ""
+// Uploads the content of the reader to the uploadPath. The size is the number
+// of bytes that will be read from the reader, if it is unknown use a
+// negative size. The reader is not closed
+// For example:
+//   r: bytes.NewReader(data)
+//   size: int64(len(data))
+//   UploadPath: test/uploads/something.txt
+//
+// https://www.copy.com/developer/documentation#api-calls/filesystem
+func (fs *FileService) UploadReader(r io.Reader, size int64, uploadPath string, opts *UploadOptions) error {
+	return fs.UploadReaderContext(context.Background(), r, size, uploadPath, opts)
+}
+
+// Like UploadReader but the request is made with the context
+func (fs *FileService) UploadReaderContext(ctx context.Context, r io.Reader, size int64, uploadPath string, opts *UploadOptions) error {
+
+	if opts == nil {
+		opts = &UploadOptions{}
 	}
 
-	// Sanitize path again
-	uploadPath = strings.Trim(uploadPath, "/")
+	finalUrl, filename, err := uploadFileUrl(uploadPath, opts.Overwrite)
 
-	// Create final path
-	uploadPath = fmt.Sprintf(filesCreateSuffix, uploadPath, overwrite)
+	if err != nil {
+		return err
+	}
 
-	_, err := fs.client.DoRequestMultipart(filePath, uploadPath, filename, "POST")
+	_, err = fs.client.doRequestMultipart(ctx, r, size, finalUrl, filename, "POST", opts)
 
 	if err != nil {
 		return err
@@ -265,7 +340,56 @@ func (fs *FileService) UploadFile(filePath, uploadPath string, overwrite bool) e
 //
 // https://www.copy.com/developer/documentation#api-calls/filesystem
 func (fs *FileService) UpdateFile(filePath, uploadPath string) error {
+	return fs.UpdateFileContext(context.Background(), filePath, uploadPath)
+}
+
+// Like UpdateFile but the request is made with the context
+func (fs *FileService) UpdateFileContext(ctx context.Context, filePath, uploadPath string) error {
+	return fs.UpdateFileWithOptions(ctx, filePath, uploadPath, nil)
+}
+
+// Like UpdateFile but with the upload options (progress...)
+func (fs *FileService) UpdateFileWithOptions(ctx context.Context, filePath, uploadPath string, opts *UploadOptions) error {
+
+	file, size, err := openUploadFile(filePath)
+
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return fs.UpdateReaderContext(ctx, file, size, uploadPath, opts)
+}
 
+// Uploads the content of the reader (updating the file) to the uploadPath.
+// The size is the number of bytes that will be read from the reader, if it
+// is unknown use a negative size. The reader is not closed
+//
+// https://www.copy.com/developer/documentation#api-calls/filesystem
+func (fs *FileService) UpdateReader(r io.Reader, size int64, uploadPath string, opts *UploadOptions) error {
+	return fs.UpdateReaderContext(context.Background(), r, size, uploadPath, opts)
+}
+
+// Like UpdateReader but the request is made with the context
+func (fs *FileService) UpdateReaderContext(ctx context.Context, r io.Reader, size int64, uploadPath string, opts *UploadOptions) error {
+
+	finalUrl, filename, err := updateFileUrl(uploadPath)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = fs.client.doRequestMultipart(ctx, r, size, finalUrl, filename, "PUT", opts)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Returns the url for creating a file and the filename of the upload path
+func uploadFileUrl(uploadPath string, overwrite bool) (string, string, error) {
 	// Sanitize path
 	uploadPath = strings.Trim(uploadPath, "/")
 
@@ -273,40 +397,68 @@ func (fs *FileService) UpdateFile(filePath, uploadPath string) error {
 	filename := filepath.Base(uploadPath)
 
 	if filename == "" {
-		return errors.New("Wrong uploadPath")
+		return "", "", errors.New("Wrong uploadPath")
 	}
 
-	uploadPath = strings.Join([]string{filesTopLevelSuffix, uploadPath}, "/")
+	// Get upload path
+	uploadPath = filepath.Dir(uploadPath)
+
+	if uploadPath == "." { // Check if is at root, if so delete the point returned by Dir
+		uploadPath = ""
+	}
 
-	_, err := fs.client.DoRequestMultipart(filePath, uploadPath, filename, "PUT")
+	// Sanitize path again
+	uploadPath = strings.Trim(uploadPath, "/")
 
-	if err != nil {
-		return err
+	// Create final path
+	return fmt.Sprintf(filesCreateSuffix, uploadPath, overwrite), filename, nil
+}
+
+// Returns the url for updating a file and the filename of the upload path
+func updateFileUrl(uploadPath string) (string, string, error) {
+	// Sanitize path
+	uploadPath = strings.Trim(uploadPath, "/")
+
+	// Get upload filename
+	filename := filepath.Base(uploadPath)
+
+	if filename == "" {
+		return "", "", errors.New("Wrong uploadPath")
 	}
 
-	return nil
+	return strings.Join([]string{filesTopLevelSuffix, uploadPath}, "/"), filename, nil
 }
 
 // Renames the file
 //
 // https://www.copy.com/developer/documentation#api-calls/filesystem
 func (fs *FileService) RenameFile(path string, newName string, overwrite bool) error {
+	return fs.RenameFileContext(context.Background(), path, newName, overwrite)
+}
+
+// Like RenameFile but the request is made with the context
+func (fs *FileService) RenameFileContext(ctx context.Context, path string, newName string, overwrite bool) error {
 	path = strings.Trim(path, "/")
-	return fs.moveOrRenameFile(fmt.Sprintf(filesRenameSuffix, path, newName, overwrite))
+	return fs.moveOrRenameFile(ctx, fmt.Sprintf(filesRenameSuffix, path, newName, overwrite))
 }
 
 // Moves the file
 //
 // https://www.copy.com/developer/documentation#api-calls/filesystem
 func (fs *FileService) MoveFile(path string, newPath string, overwrite bool) error {
+	return fs.MoveFileContext(context.Background(), path, newPath, overwrite)
+}
+
+// Like MoveFile but the request is made with the context
+func (fs *FileService) MoveFileContext(ctx context.Context, path string, newPath string, overwrite bool) error {
 	path = strings.Trim(path, "/")
 	newPath = strings.Trim(newPath, "/")
-	return fs.moveOrRenameFile(fmt.Sprintf(filesMoveSuffix, path, newPath, overwrite))
+	return fs.moveOrRenameFile(ctx, fmt.Sprintf(filesMoveSuffix, path, newPath, overwrite))
 }
 
 // Move and rename calls are similar, wrap in this function for convienence
-func (fs *FileService) moveOrRenameFile(finalUrl string) error {
-	_, err := fs.client.DoRequestDecoding("PUT", finalUrl, nil, nil)
+func (fs *FileService) moveOrRenameFile(ctx context.Context, finalUrl string) error {
+	_, err := fs.client.DoRequestDecodingContext(ctx, "PUT", finalUrl, nil, nil)
 
 	if err != nil {
 		return err
@@ -319,8 +471,13 @@ func (fs *FileService) moveOrRenameFile(finalUrl string) error {
 //
 // https://www.copy.com/developer/documentation#api-calls/filesystem
 func (fs *FileService) CreateDirectory(path string, overwrite bool) error {
+	return fs.CreateDirectoryContext(context.Background(), path, overwrite)
+}
+
+// Like CreateDirectory but the request is made with the context
+func (fs *FileService) CreateDirectoryContext(ctx context.Context, path string, overwrite bool) error {
 	path = strings.Trim(path, "/")
-	_, err := fs.client.DoRequestDecoding("POST", fmt.Sprintf(filesCreateSuffix, path, overwrite), nil, nil)
+	_, err := fs.client.DoRequestDecodingContext(ctx, "POST", fmt.Sprintf(filesCreateSuffix, path, overwrite), nil, nil)
 
 	if err != nil {
 		return err
@@ -334,6 +491,11 @@ func (fs *FileService) CreateDirectory(path string, overwrite bool) error {
 //
 // https://www.copy.com/developer/documentation#api-calls/filesystem
 func (fs *FileService) GetThumbnail(path string, size int) (io.ReadCloser, error) {
+	return fs.GetThumbnailContext(context.Background(), path, size)
+}
+
+// Like GetThumbnail but the request is made with the context
+func (fs *FileService) GetThumbnailContext(ctx context.Context, path string, size int) (io.ReadCloser, error) {
 
 	if size != 32 && size != 64 && size != 128 && size != 256 && size != 512 && size != 1024 {
 		return nil, errors.New("Wrong thumbnail size")
@@ -341,7 +503,7 @@ func (fs *FileService) GetThumbnail(path string, size int) (io.ReadCloser, error
 
 	path = strings.Trim(path, "/")
 
-	resp, err := fs.client.DoRequestContent(strings.Join([]string{thumbsTopLevelSuffix, path}, "/"),
+	resp, err := fs.client.DoRequestContentContext(ctx, strings.Join([]string{thumbsTopLevelSuffix, path}, "/"),
 		map[string][]string{"size": []string{fmt.Sprintf("%d", size)}})
 
 	if err != nil {
@@ -350,3 +512,19 @@ func (fs *FileService) GetThumbnail(path string, size int) (io.ReadCloser, error
 
 	return resp.Body, nil
 }
+
+// Opens the file that will be uploaded, returns the file and the size
+func openUploadFile(filePath string) (*os.File, int64, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+
+	return file, info.Size(), nil
+}
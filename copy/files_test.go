@@ -2,6 +2,8 @@ package copy
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -154,7 +156,7 @@ func TestJsonMetaDecoding(t *testing.T) {
 		Permissions:        "all",
 		Public:             true,
 		Size:               3123123,
-		DateLastSynced:     32131232,
+		DateLastSynced:     NewTimestamp(32131232),
 		Share:              true,
 		RecipientConfirmed: true,
 		ObjectAvailable:    true,
@@ -193,7 +195,7 @@ func TestJsonMetaDecoding(t *testing.T) {
 		Revisions: []Revision{
 			Revision{
 				RevisionId:   "231312",
-				ModifiedTime: "32324",
+				ModifiedTime: NewTimestamp(32324),
 				Size:         31232,
 				Latest:       true,
 				Conflict:     4324,
@@ -201,7 +203,7 @@ func TestJsonMetaDecoding(t *testing.T) {
 				Type:         "sdsad",
 				Creator: Creator{
 					UserId:      "44342",
-					CreatedTime: 323423,
+					CreatedTime: NewTimestamp(323423),
 					Email:       "fdfdsf@dsadsa.com",
 					FirstName:   "sadasd",
 					LastName:    "sdsadsafds",
@@ -310,8 +312,8 @@ func TestGetMeta(t *testing.T) {
 		Path:               "/testing",
 		Name:               "testing",
 		Type:               "dir",
-		DateLastSynced:     1386150047,
-		ModifiedTime:       1386150047,
+		DateLastSynced:     NewTimestamp(1386150047),
+		ModifiedTime:       NewTimestamp(1386150047),
 		Stub:               false,
 		RecipientConfirmed: false,
 		Syncing:            false,
@@ -326,8 +328,8 @@ func TestGetMeta(t *testing.T) {
 				Name:               "random.txt",
 				Type:               "file",
 				Size:               1258291200,
-				DateLastSynced:     1386151250,
-				ModifiedTime:       1385993169,
+				DateLastSynced:     NewTimestamp(1386151250),
+				ModifiedTime:       NewTimestamp(1385993169),
 				Stub:               true,
 				RecipientConfirmed: false,
 				MimeType:           "text/plain",
@@ -448,7 +450,7 @@ func TestListRevisionsMeta(t *testing.T) {
 	perfectRevisions := []Revision{
 		Revision{
 			RevisionId:   "5000",
-			ModifiedTime: "1365543105",
+			ModifiedTime: NewTimestamp(1365543105),
 			Size:         12670,
 			Latest:       true,
 			Conflict:     4324,
@@ -456,7 +458,7 @@ func TestListRevisionsMeta(t *testing.T) {
 			Type:         "revision",
 			Creator: Creator{
 				UserId:      "1381231",
-				CreatedTime: 1358175510,
+				CreatedTime: NewTimestamp(1358175510),
 				Email:       "thomashunter@example.com",
 				FirstName:   "Thomas",
 				LastName:    "Hunter",
@@ -465,7 +467,7 @@ func TestListRevisionsMeta(t *testing.T) {
 		},
 		Revision{
 			RevisionId:   "4900",
-			ModifiedTime: "1365542000",
+			ModifiedTime: NewTimestamp(1365542000),
 			Size:         12661,
 			Latest:       false,
 			Conflict:     4324,
@@ -473,7 +475,7 @@ func TestListRevisionsMeta(t *testing.T) {
 			Type:         "revision",
 			Creator: Creator{
 				UserId:      "1381231",
-				CreatedTime: 1358175510,
+				CreatedTime: NewTimestamp(1358175510),
 				Email:       "thomashunter@example.com",
 				FirstName:   "Thomas",
 				LastName:    "Hunter",
@@ -482,7 +484,7 @@ func TestListRevisionsMeta(t *testing.T) {
 		},
 		Revision{
 			RevisionId:   "4800",
-			ModifiedTime: "1365543073",
+			ModifiedTime: NewTimestamp(1365543073),
 			Size:         12658,
 			Latest:       false,
 			Conflict:     4324,
@@ -490,7 +492,7 @@ func TestListRevisionsMeta(t *testing.T) {
 			Type:         "revision",
 			Creator: Creator{
 				UserId:      "1381231",
-				CreatedTime: 1358175510,
+				CreatedTime: NewTimestamp(1358175510),
 				Email:       "thomashunter@example.com",
 				FirstName:   "Thomas",
 				LastName:    "Hunter",
@@ -809,7 +811,7 @@ func TestGetRevisionMeta(t *testing.T) {
 		Public:             false,
 		Type:               "file",
 		Size:               12666,
-		DateLastSynced:     1365532651,
+		DateLastSynced:     NewTimestamp(1365532651),
 		Stub:               false,
 		RecipientConfirmed: false,
 		Url:                "https://copy.com/web/Big%20API%20Changes/API-Changes.md?revision=4898",
@@ -925,3 +927,163 @@ func TestFileUpdate(t *testing.T) {
 		t.Errorf("No server up, should be an error")
 	}
 }
+
+func TestUploadReader(t *testing.T) {
+	setupFileService(t)
+	defer tearDownFileService()
+
+	upPath := "tests/uploads"
+	origFile := []byte("generated content that never touched the disk")
+
+	resPath := strings.Join([]string{"", filesTopLevelSuffix, upPath}, "/")
+
+	mux.HandleFunc(resPath,
+		func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, "POST")
+
+			if r.URL.Query().Get("overwrite") != "true" {
+				t.Errorf("Wrong params in URL")
+			}
+
+			// Check that upload is ok
+			r.ParseMultipartForm(100000)
+			form := r.MultipartForm
+
+			files, _ := form.File["file"]
+			file, _ := files[0].Open()
+			defer file.Close()
+
+			if files[0].Filename != "generated.txt" {
+				t.Errorf("Wrong filename: %s", files[0].Filename)
+			}
+
+			buf := new(bytes.Buffer)
+			io.Copy(buf, file)
+
+			if !bytes.Equal(origFile, buf.Bytes()) {
+				t.Errorf("contents are not equal")
+			}
+		},
+	)
+
+	// From memory
+	err := fileService.UploadReader(bytes.NewReader(origFile), int64(len(origFile)),
+		upPath+"/generated.txt", &UploadOptions{Overwrite: true})
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	// From a pipe without knowing the size
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write(origFile)
+		pw.Close()
+	}()
+
+	err = fileService.UploadReader(pr, -1, upPath+"/generated.txt", &UploadOptions{Overwrite: true})
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	// Short reader
+	err = fileService.UploadReader(bytes.NewReader(origFile), int64(len(origFile)+10),
+		upPath+"/generated.txt", &UploadOptions{Overwrite: true})
+
+	if err == nil {
+		t.Errorf("Reader smaller than the size, should be an error")
+	}
+
+	// Test bad request
+	server.Close()
+	if err := fileService.UploadReader(bytes.NewReader(origFile), int64(len(origFile)), upPath+"/generated.txt", nil); err == nil {
+		t.Errorf("No server up, should be an error")
+	}
+}
+
+func TestUpdateReader(t *testing.T) {
+	setupFileService(t)
+	defer tearDownFileService()
+
+	upPath := "tests/uploads/generated.txt"
+	origFile := []byte("generated content that never touched the disk")
+
+	resPath := strings.Join([]string{"", filesTopLevelSuffix, upPath}, "/")
+
+	mux.HandleFunc(resPath,
+		func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, "PUT")
+
+			// Check that upload is ok
+			r.ParseMultipartForm(100000)
+			form := r.MultipartForm
+
+			files, _ := form.File["file"]
+			file, _ := files[0].Open()
+			defer file.Close()
+
+			buf := new(bytes.Buffer)
+			io.Copy(buf, file)
+
+			if !bytes.Equal(origFile, buf.Bytes()) {
+				t.Errorf("contents are not equal")
+			}
+		},
+	)
+
+	err := fileService.UpdateReader(bytes.NewReader(origFile), int64(len(origFile)), upPath, nil)
+
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	// Test bad request
+	server.Close()
+	if err := fileService.UpdateReader(bytes.NewReader(origFile), int64(len(origFile)), upPath, nil); err == nil {
+		t.Errorf("No server up, should be an error")
+	}
+}
+
+// Checks that the cancellation of the context stops the file download
+func TestGetFileContext(t *testing.T) {
+	setupFileService(t)
+	defer tearDownFileService()
+
+	filename := "big.bin"
+	block := make(chan struct{})
+	defer close(block)
+
+	mux.HandleFunc(strings.Join([]string{"", filesTopLevelSuffix, filename}, "/"),
+		func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, "GET")
+			w.Write([]byte("first chunk"))
+			w.(http.Flusher).Flush()
+
+			// Never ends
+			select {
+			case <-block:
+			case <-r.Context().Done():
+			}
+		},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	fileReader, err := fileService.GetFileContext(ctx, filename)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer fileReader.Close()
+
+	buf := make([]byte, len("first chunk"))
+	if _, err := io.ReadFull(fileReader, buf); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	cancel()
+
+	if _, err := ioutil.ReadAll(fileReader); !errors.Is(err, context.Canceled) {
+		t.Errorf("Should be a cancelled error, got: %v", err)
+	}
+}
@@ -1,11 +1,14 @@
 package copy
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
 	"strings"
 )
 
-// Use the object Meta from files (move to other file?)
-
 type LinkService struct {
 	client *Client
 }
@@ -13,7 +16,8 @@ type LinkService struct {
 var (
 	// Links paths
 	linksTopLevelSuffix = "links"
-	linksGetSuffix      = strings.Join([]string{linksTopLevelSuffix, "%v"}, "/") // https://.../links/TOKEN
+	linksGetSuffix      = strings.Join([]string{linksTopLevelSuffix, "%v"}, "/")                     // https://.../links/TOKEN
+	linksMetaSuffix     = strings.Join([]string{metaTopLevelSuffix, linksTopLevelSuffix, "%v"}, "/") // https://.../meta/links/TOKEN
 )
 
 func NewLinkService(client *Client) *LinkService {
@@ -22,30 +26,204 @@ func NewLinkService(client *Client) *LinkService {
 	return fs
 }
 
-func (ls *LinkService) GetLink(token string) (*Meta, error) {
-	return nil, nil
+// Returns the information of a link
+//
+// https://www.copy.com/developer/documentation#api-calls/links
+func (ls *LinkService) GetLink(token string) (*Link, error) {
+	return ls.GetLinkContext(context.Background(), token)
 }
 
-func (ls *LinkService) GetLinks() ([]Meta, error) {
-	return nil, nil
+// Like GetLink but the request is made with the context
+func (ls *LinkService) GetLinkContext(ctx context.Context, token string) (*Link, error) {
+	if token == "" {
+		return nil, errors.New("Wrong link token")
+	}
+
+	link := new(Link)
+	_, err := ls.client.DoRequestDecodingContext(ctx, "GET", fmt.Sprintf(linksGetSuffix, token), nil, link)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return link, nil
 }
 
-func (ls *LinkService) CreateLink(name string, paths []string, public bool) error {
-	return nil
+// Returns all the links of the user
+//
+// https://www.copy.com/developer/documentation#api-calls/links
+func (ls *LinkService) GetLinks() ([]Link, error) {
+	return ls.GetLinksContext(context.Background())
+}
+
+// Like GetLinks but the request is made with the context
+func (ls *LinkService) GetLinksContext(ctx context.Context) ([]Link, error) {
+	links := []Link{}
+	_, err := ls.client.DoRequestDecodingContext(ctx, "GET", linksTopLevelSuffix, nil, &links)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return links, nil
 }
 
+// Creates a new link with the name and the paths, the link will be public
+// or private depending on the public flag
+//
+// https://www.copy.com/developer/documentation#api-calls/links
+func (ls *LinkService) CreateLink(name string, paths []string, public bool) (*Link, error) {
+	return ls.CreateLinkContext(context.Background(), name, paths, public)
+}
+
+// Like CreateLink but the request is made with the context
+func (ls *LinkService) CreateLinkContext(ctx context.Context, name string, paths []string, public bool) (*Link, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("A link needs at least one path")
+	}
+
+	values := url.Values{
+		"name":    {name},
+		"public":  {strconv.FormatBool(public)},
+		"paths[]": sanitizeLinkPaths(paths),
+	}
+
+	link := new(Link)
+	_, err := ls.client.DoRequestDecodingContext(ctx, "POST", linksTopLevelSuffix, values, link)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return link, nil
+}
+
+// Adds paths to an existing link
+//
+// https://www.copy.com/developer/documentation#api-calls/links
 func (ls *LinkService) AddPaths(token string, paths []string) error {
+	return ls.AddPathsContext(context.Background(), token, paths)
+}
+
+// Like AddPaths but the request is made with the context
+func (ls *LinkService) AddPathsContext(ctx context.Context, token string, paths []string) error {
+	if token == "" {
+		return errors.New("Wrong link token")
+	}
+
+	if len(paths) == 0 {
+		return errors.New("No paths to add")
+	}
+
+	values := url.Values{
+		"paths[]": sanitizeLinkPaths(paths),
+	}
+
+	_, err := ls.client.DoRequestDecodingContext(ctx, "PUT", fmt.Sprintf(linksGetSuffix, token), values, nil)
+
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// Adds recipients to an existing link
+//
+// https://www.copy.com/developer/documentation#api-calls/links
 func (ls *LinkService) AddRecipients(token string, recipients []Recipient) error {
+	return ls.AddRecipientsContext(context.Background(), token, recipients)
+}
+
+// Like AddRecipients but the request is made with the context
+func (ls *LinkService) AddRecipientsContext(ctx context.Context, token string, recipients []Recipient) error {
+	if token == "" {
+		return errors.New("Wrong link token")
+	}
+
+	if len(recipients) == 0 {
+		return errors.New("No recipients to add")
+	}
+
+	values := url.Values{}
+	for i, r := range recipients {
+		prefix := fmt.Sprintf("recipients[%d]", i)
+		addLinkValue(values, prefix, "contact_type", r.ContactType)
+		addLinkValue(values, prefix, "contact_id", r.ContactId)
+		addLinkValue(values, prefix, "contact_source", r.ContactSource)
+		addLinkValue(values, prefix, "user_id", r.UserId)
+		addLinkValue(values, prefix, "first_name", r.FirstName)
+		addLinkValue(values, prefix, "last_name", r.LastName)
+		addLinkValue(values, prefix, "email", r.Email)
+		addLinkValue(values, prefix, "permissions", r.Permissions)
+	}
+
+	_, err := ls.client.DoRequestDecodingContext(ctx, "PUT", fmt.Sprintf(linksGetSuffix, token), values, nil)
+
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// Deletes a link
+//
+// https://www.copy.com/developer/documentation#api-calls/links
 func (ls *LinkService) DeleteLink(token string) error {
+	return ls.DeleteLinkContext(context.Background(), token)
+}
+
+// Like DeleteLink but the request is made with the context
+func (ls *LinkService) DeleteLinkContext(ctx context.Context, token string) error {
+	if token == "" {
+		return errors.New("Wrong link token")
+	}
+
+	_, err := ls.client.DoRequestDecodingContext(ctx, "DELETE", fmt.Sprintf(linksGetSuffix, token), nil, nil)
+
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// Returns the metadata of the files attached to a link
+//
+// https://www.copy.com/developer/documentation#api-calls/links
 func (ls *LinkService) GetFilesMetaFromLink(token string) (*Meta, error) {
-	return nil, nil
+	return ls.GetFilesMetaFromLinkContext(context.Background(), token)
+}
+
+// Like GetFilesMetaFromLink but the request is made with the context
+func (ls *LinkService) GetFilesMetaFromLinkContext(ctx context.Context, token string) (*Meta, error) {
+	if token == "" {
+		return nil, errors.New("Wrong link token")
+	}
+
+	meta := new(Meta)
+	_, err := ls.client.DoRequestDecodingContext(ctx, "GET", fmt.Sprintf(linksMetaSuffix, token), nil, meta)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return meta, nil
+}
+
+// Copy paths are absolute from the root of the user files
+func sanitizeLinkPaths(paths []string) []string {
+	res := make([]string, len(paths))
+	for i, p := range paths {
+		res[i] = "/" + strings.Trim(p, "/")
+	}
+	return res
+}
+
+// Only send the recipient fields that are set
+func addLinkValue(values url.Values, prefix, key, value string) {
+	if value != "" {
+		values.Set(fmt.Sprintf("%s[%s]", prefix, key), value)
+	}
 }
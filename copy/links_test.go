@@ -1,6 +1,9 @@
 package copy
 
 import (
+	"fmt"
+	"net/http"
+	"reflect"
 	"testing"
 )
 
@@ -16,3 +19,322 @@ func setupLinkService(t *testing.T) {
 func tearDownLinkService() {
 	defer tearDown()
 }
+
+var linkJson = `{
+    "id":"MBrss3roGDk4",
+    "name":"My Cool Shared Files",
+    "public":true,
+    "url":"https:\/\/copy.com\/MBrss3roGDk4",
+    "url_short":"https:\/\/copy.com\/MBrss3roGDk4",
+    "creator_id":"1381231",
+    "confirmation_required":false,
+    "permissions":"read",
+    "recipients":[
+        {
+            "contact_type":"user",
+            "contact_id":"user-1381231",
+            "contact_source":"link-user",
+            "user_id":"1381231",
+            "first_name":"Thomas",
+            "last_name":"Hunter",
+            "email":"thomashunter@example.com",
+            "permissions":"read"
+        }
+    ]
+}`
+
+var perfectLink = Link{
+	Id:                   "MBrss3roGDk4",
+	Name:                 "My Cool Shared Files",
+	Public:               true,
+	Url:                  "https://copy.com/MBrss3roGDk4",
+	UrlShort:             "https://copy.com/MBrss3roGDk4",
+	CreatorId:            "1381231",
+	ConfirmationRequired: false,
+	Permissions:          "read",
+	Recipients: []Recipient{
+		Recipient{
+			ContactType:   "user",
+			ContactId:     "user-1381231",
+			ContactSource: "link-user",
+			UserId:        "1381231",
+			FirstName:     "Thomas",
+			LastName:      "Hunter",
+			Email:         "thomashunter@example.com",
+			Permissions:   "read",
+		},
+	},
+}
+
+func TestGetLink(t *testing.T) {
+	setupLinkService(t)
+	defer tearDownLinkService()
+
+	mux.HandleFunc("/"+fmt.Sprintf(linksGetSuffix, "MBrss3roGDk4"),
+		func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, "GET")
+			fmt.Fprint(w, linkJson)
+		},
+	)
+
+	link, err := linkService.GetLink("MBrss3roGDk4")
+
+	if err != nil {
+		t.Errorf("Shouldn't be an error")
+	}
+
+	// Are bouth content equal?
+	if !reflect.DeepEqual(*link, perfectLink) {
+		t.Errorf("Links are not equal")
+	}
+
+	if _, err := linkService.GetLink(""); err == nil {
+		t.Errorf("Empty token, should be an error")
+	}
+
+	// Test bad request
+	server.Close()
+	if _, err := linkService.GetLink("MBrss3roGDk4"); err == nil {
+		t.Errorf("No server up, should be an error")
+	}
+}
+
+func TestGetLinks(t *testing.T) {
+	setupLinkService(t)
+	defer tearDownLinkService()
+
+	mux.HandleFunc("/"+linksTopLevelSuffix,
+		func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, "GET")
+			fmt.Fprintf(w, "[%s, %s]", linkJson, linkJson)
+		},
+	)
+
+	links, err := linkService.GetLinks()
+
+	if err != nil {
+		t.Errorf("Shouldn't be an error")
+	}
+
+	// Are bouth content equal?
+	if !reflect.DeepEqual(links, []Link{perfectLink, perfectLink}) {
+		t.Errorf("Links are not equal")
+	}
+
+	// Test bad request
+	server.Close()
+	if _, err := linkService.GetLinks(); err == nil {
+		t.Errorf("No server up, should be an error")
+	}
+}
+
+func TestCreateLink(t *testing.T) {
+	setupLinkService(t)
+	defer tearDownLinkService()
+
+	name := "My Cool Shared Files"
+	paths := []string{"Big API Changes/API-Changes.md", "/photos/"}
+
+	mux.HandleFunc("/"+linksTopLevelSuffix,
+		func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, "POST")
+			r.ParseForm()
+
+			if r.PostForm.Get("name") != name || r.PostForm.Get("public") != "true" {
+				t.Errorf("Wrong params in body")
+			}
+
+			wantPaths := []string{"/Big API Changes/API-Changes.md", "/photos"}
+			if !reflect.DeepEqual(r.PostForm["paths[]"], wantPaths) {
+				t.Errorf("Wrong paths in body")
+			}
+
+			fmt.Fprint(w, linkJson)
+		},
+	)
+
+	link, err := linkService.CreateLink(name, paths, true)
+
+	if err != nil {
+		t.Errorf("Shouldn't be an error")
+	}
+
+	if !reflect.DeepEqual(*link, perfectLink) {
+		t.Errorf("Links are not equal")
+	}
+
+	if _, err := linkService.CreateLink(name, nil, true); err == nil {
+		t.Errorf("No paths, should be an error")
+	}
+
+	// Test bad request
+	server.Close()
+	if _, err := linkService.CreateLink(name, paths, true); err == nil {
+		t.Errorf("No server up, should be an error")
+	}
+}
+
+func TestAddPaths(t *testing.T) {
+	setupLinkService(t)
+	defer tearDownLinkService()
+
+	mux.HandleFunc("/"+fmt.Sprintf(linksGetSuffix, "MBrss3roGDk4"),
+		func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, "PUT")
+			r.ParseForm()
+
+			if !reflect.DeepEqual(r.PostForm["paths[]"], []string{"/test/test2.txt"}) {
+				t.Errorf("Wrong paths in body")
+			}
+
+			fmt.Fprint(w, linkJson)
+		},
+	)
+
+	if err := linkService.AddPaths("MBrss3roGDk4", []string{"test/test2.txt"}); err != nil {
+		t.Errorf("Shouldn't be an error")
+	}
+
+	if err := linkService.AddPaths("MBrss3roGDk4", nil); err == nil {
+		t.Errorf("No paths, should be an error")
+	}
+
+	// Test bad request
+	server.Close()
+	if err := linkService.AddPaths("MBrss3roGDk4", []string{"test/test2.txt"}); err == nil {
+		t.Errorf("No server up, should be an error")
+	}
+}
+
+func TestAddRecipients(t *testing.T) {
+	setupLinkService(t)
+	defer tearDownLinkService()
+
+	recipients := []Recipient{
+		Recipient{Email: "thomashunter@example.com", Permissions: "read"},
+		Recipient{ContactType: "user", UserId: "1381231"},
+	}
+
+	mux.HandleFunc("/"+fmt.Sprintf(linksGetSuffix, "MBrss3roGDk4"),
+		func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, "PUT")
+			r.ParseForm()
+
+			if r.PostForm.Get("recipients[0][email]") != "thomashunter@example.com" ||
+				r.PostForm.Get("recipients[0][permissions]") != "read" ||
+				r.PostForm.Get("recipients[1][contact_type]") != "user" ||
+				r.PostForm.Get("recipients[1][user_id]") != "1381231" {
+				t.Errorf("Wrong recipients in body")
+			}
+
+			if _, ok := r.PostForm["recipients[1][email]"]; ok {
+				t.Errorf("Empty fields shouldn't be sent")
+			}
+
+			fmt.Fprint(w, linkJson)
+		},
+	)
+
+	if err := linkService.AddRecipients("MBrss3roGDk4", recipients); err != nil {
+		t.Errorf("Shouldn't be an error")
+	}
+
+	if err := linkService.AddRecipients("", recipients); err == nil {
+		t.Errorf("Empty token, should be an error")
+	}
+
+	// Test bad request
+	server.Close()
+	if err := linkService.AddRecipients("MBrss3roGDk4", recipients); err == nil {
+		t.Errorf("No server up, should be an error")
+	}
+}
+
+func TestDeleteLink(t *testing.T) {
+	setupLinkService(t)
+	defer tearDownLinkService()
+
+	mux.HandleFunc("/"+fmt.Sprintf(linksGetSuffix, "MBrss3roGDk4"),
+		func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, "DELETE")
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+
+	if err := linkService.DeleteLink("MBrss3roGDk4"); err != nil {
+		t.Errorf("Shouldn't be an error")
+	}
+
+	if err := linkService.DeleteLink("doesntexist"); err == nil {
+		t.Errorf("Not found link, should be an error")
+	}
+
+	// Test bad request
+	server.Close()
+	if err := linkService.DeleteLink("MBrss3roGDk4"); err == nil {
+		t.Errorf("No server up, should be an error")
+	}
+}
+
+func TestGetFilesMetaFromLink(t *testing.T) {
+	setupLinkService(t)
+	defer tearDownLinkService()
+
+	mux.HandleFunc("/"+fmt.Sprintf(linksMetaSuffix, "MBrss3roGDk4"),
+		func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, "GET")
+			fmt.Fprint(w,
+				`{
+                   "id":"\/links\/MBrss3roGDk4",
+                   "path":"\/",
+                   "name":"My Cool Shared Files",
+                   "type":"link",
+                   "token":"MBrss3roGDk4",
+                   "children":[
+                      {
+                         "id":"\/links\/MBrss3roGDk4\/API-Changes.md",
+                         "path":"\/API-Changes.md",
+                         "name":"API-Changes.md",
+                         "type":"file",
+                         "size":12670
+                      }
+                   ],
+                   "children_count":1
+                }`)
+		},
+	)
+
+	meta, err := linkService.GetFilesMetaFromLink("MBrss3roGDk4")
+
+	if err != nil {
+		t.Errorf("Shouldn't be an error")
+	}
+
+	perfectMeta := Meta{
+		Id:    "/links/MBrss3roGDk4",
+		Path:  "/",
+		Name:  "My Cool Shared Files",
+		Type:  "link",
+		Token: "MBrss3roGDk4",
+		Children: []Meta{
+			Meta{
+				Id:   "/links/MBrss3roGDk4/API-Changes.md",
+				Path: "/API-Changes.md",
+				Name: "API-Changes.md",
+				Type: "file",
+				Size: 12670,
+			},
+		},
+		ChildrenCount: 1,
+	}
+
+	if !reflect.DeepEqual(*meta, perfectMeta) {
+		t.Errorf("Metas are not equal")
+	}
+
+	// Test bad request
+	server.Close()
+	if _, err := linkService.GetFilesMetaFromLink("MBrss3roGDk4"); err == nil {
+		t.Errorf("No server up, should be an error")
+	}
+}
@@ -0,0 +1,177 @@
+package copy
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// Options for listing the children of a directory
+type ListOptions struct {
+	// Number of children asked in every page, 0 lets Copy decide
+	PageSize int
+
+	// Field used by Copy for sorting the children (name, size,
+	// modified_time...), empty for the default order
+	SortBy string
+
+	// Sort in descending order
+	SortDesc bool
+
+	// Only return the children of these types (file, dir...), empty
+	// returns all
+	Types []string
+}
+
+// ChildrenIterator iterates over the children of a directory asking for the
+// pages when needed, only a page is held in memory. Use it like a
+// bufio.Scanner:
+//
+//	it := fs.ListChildren(ctx, "photos", nil)
+//	for it.Next() {
+//		meta := it.Meta()
+//		...
+//	}
+//	if err := it.Err(); err != nil {...}
+type ChildrenIterator struct {
+	fs      *FileService
+	ctx     context.Context
+	dirPath string
+	opts    ListOptions
+
+	page    []Meta
+	pos     int
+	current *Meta
+	parent  *Meta
+
+	// Pagination state
+	started   bool
+	finished  bool
+	nextIndex int
+	fetched   int
+
+	err error
+}
+
+// Returns an iterator over the children of the directory at path
+func (fs *FileService) ListChildren(ctx context.Context, path string, opts *ListOptions) *ChildrenIterator {
+	it := &ChildrenIterator{
+		fs:      fs,
+		ctx:     ctx,
+		dirPath: path,
+	}
+
+	if opts != nil {
+		it.opts = *opts
+	}
+
+	return it
+}
+
+// Advances to the next child, returns false when there are no more children
+// or there is an error
+func (it *ChildrenIterator) Next() bool {
+	for {
+		if it.err != nil {
+			return false
+		}
+
+		if it.pos < len(it.page) {
+			it.current = &it.page[it.pos]
+			it.pos++
+
+			if it.wanted(it.current) {
+				return true
+			}
+			continue
+		}
+
+		if it.finished {
+			it.current = nil
+			return false
+		}
+
+		it.fetchPage()
+	}
+}
+
+// Returns the current child
+func (it *ChildrenIterator) Meta() *Meta {
+	return it.current
+}
+
+// Returns the metadata of the directory (without the children), available
+// after the first call to Next
+func (it *ChildrenIterator) Parent() *Meta {
+	return it.parent
+}
+
+// Returns the error of the iteration (if any)
+func (it *ChildrenIterator) Err() error {
+	return it.err
+}
+
+// Checks if the child passes the type filter
+func (it *ChildrenIterator) wanted(meta *Meta) bool {
+	if len(it.opts.Types) == 0 {
+		return true
+	}
+
+	for _, t := range it.opts.Types {
+		if meta.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Gets the next page of children
+func (it *ChildrenIterator) fetchPage() {
+	form := url.Values{}
+	if it.started {
+		form.Set("list_index", strconv.Itoa(it.nextIndex))
+	}
+	if it.opts.PageSize > 0 {
+		form.Set("list_count", strconv.Itoa(it.opts.PageSize))
+	}
+	if it.opts.SortBy != "" {
+		form.Set("sort", it.opts.SortBy)
+		if it.opts.SortDesc {
+			form.Set("sort_direction", "desc")
+		} else {
+			form.Set("sort_direction", "asc")
+		}
+	}
+
+	meta, err := it.fs.getMetaPage(it.ctx, it.dirPath, form)
+	if err != nil {
+		it.err = err
+		return
+	}
+
+	page := meta.Children
+
+	// Copy doesn't know about the index, avoid asking forever
+	if len(page) == 0 || (it.started && page[0].ListIndex < it.nextIndex) {
+		page = nil
+		it.finished = true
+	}
+
+	if !it.started {
+		meta.Children = nil
+		it.parent = meta
+		it.started = true
+	}
+
+	it.page = page
+	it.pos = 0
+	it.fetched += len(page)
+
+	if len(page) > 0 {
+		it.nextIndex = page[len(page)-1].ListIndex + 1
+	}
+
+	if it.fetched >= meta.ChildrenCount {
+		it.finished = true
+	}
+}
@@ -0,0 +1,115 @@
+package copy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// Setups a directory with 10 children, 5 files and 5 dirs
+func setupListTree(t *testing.T) {
+	setupFileService(t)
+
+	mux.HandleFunc("/"+fmt.Sprintf(getMetaSuffix, "big"),
+		func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, "GET")
+
+			index, _ := strconv.Atoi(r.URL.Query().Get("list_index"))
+			count, _ := strconv.Atoi(r.URL.Query().Get("list_count"))
+			if count == 0 {
+				count = 4
+			}
+
+			if r.URL.Query().Get("sort") != "" && r.URL.Query().Get("sort_direction") != "desc" {
+				t.Errorf("Wrong sort params")
+			}
+
+			fmt.Fprint(w, `{"path":"/big", "name":"big", "type":"dir", "children_count":10, "children":[`)
+			for i := index; i < index+count && i < 10; i++ {
+				if i != index {
+					fmt.Fprint(w, ",")
+				}
+				childType := "file"
+				if i%2 == 1 {
+					childType = "dir"
+				}
+				fmt.Fprintf(w, `{"path":"/big/%d", "name":"%d", "type":"%s", "list_index":%d}`, i, i, childType, i)
+			}
+			fmt.Fprint(w, `]}`)
+		},
+	)
+}
+
+func TestListChildren(t *testing.T) {
+	setupListTree(t)
+	defer tearDownFileService()
+
+	tests := []struct {
+		opts *ListOptions
+		want []string
+	}{
+		{nil, []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}},
+		{&ListOptions{PageSize: 3}, []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}},
+		{&ListOptions{PageSize: 20}, []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}},
+		{&ListOptions{Types: []string{"dir"}}, []string{"1", "3", "5", "7", "9"}},
+		{&ListOptions{Types: []string{"file"}, SortBy: "name", SortDesc: true}, []string{"0", "2", "4", "6", "8"}},
+	}
+
+	for _, test := range tests {
+		names := []string{}
+		it := fileService.ListChildren(context.Background(), "big", test.opts)
+		for it.Next() {
+			names = append(names, it.Meta().Name)
+		}
+
+		if err := it.Err(); err != nil {
+			t.Errorf("Shouldn't be an error: %v", err)
+		}
+
+		if !reflect.DeepEqual(names, test.want) {
+			t.Errorf("Wrong children with %+v: %v", test.opts, names)
+		}
+
+		if it.Parent() == nil || it.Parent().Name != "big" || it.Parent().Children != nil {
+			t.Errorf("Wrong parent: %+v", it.Parent())
+		}
+
+		if it.Next() {
+			t.Errorf("Finished iterator shouldn't return more children")
+		}
+	}
+}
+
+func TestListChildrenErrors(t *testing.T) {
+	setupListTree(t)
+	defer tearDownFileService()
+
+	it := fileService.ListChildren(context.Background(), "doesntexist", nil)
+	if it.Next() {
+		t.Errorf("Missing dir shouldn't have children")
+	}
+
+	if !IsNotFound(it.Err()) {
+		t.Errorf("Should be a not found error: %v", it.Err())
+	}
+
+	// Cancelled in the middle
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	it = fileService.ListChildren(ctx, "big", &ListOptions{PageSize: 2})
+
+	count := 0
+	for it.Next() {
+		count++
+		if count == 2 {
+			cancel()
+		}
+	}
+
+	if count != 2 || it.Err() == nil {
+		t.Errorf("Iteration should stop after cancelling: %d, %v", count, it.Err())
+	}
+}
@@ -0,0 +1,144 @@
+package copy
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Logger is used by the client for logging the requests, *log.Logger
+// satisfies it
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Option configures the client created with New
+type Option func(*clientOptions)
+
+// The configuration that the options set
+type clientOptions struct {
+	httpClient   *http.Client
+	resourcesUrl string
+
+	credentials CredentialsProvider
+
+	userAgent   string
+	retryPolicy *RetryPolicy
+	logger      Logger
+	rateLimiter RateLimiter
+	bandwidth   *BandwidthLimiter
+}
+
+// Sets the http client that will make the requests, by default
+// http.DefaultClient
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(o *clientOptions) {
+		o.httpClient = httpClient
+	}
+}
+
+// Sets the URL of the Copy REST API, by default https://api.copy.com/rest
+func WithBaseURL(resourcesUrl string) Option {
+	return func(o *clientOptions) {
+		o.resourcesUrl = resourcesUrl
+	}
+}
+
+// Sets the app and the user access oauth tokens, these or a credentials
+// provider are required
+func WithCredentials(appToken, appSecret, accessToken, accessSecret string) Option {
+	return func(o *clientOptions) {
+		o.credentials = NewStaticCredentialsProvider(appToken, appSecret, accessToken, accessSecret)
+	}
+}
+
+// Sets the provider of the app and the user access oauth tokens, for example
+// for loading them from the env vars or a file. The provider is asked again
+// after a 401, the rotated tokens are used without creating a new client
+func WithCredentialsProvider(provider CredentialsProvider) Option {
+	return func(o *clientOptions) {
+		o.credentials = provider
+	}
+}
+
+// Sets the User-Agent header of the requests
+func WithUserAgent(userAgent string) Option {
+	return func(o *clientOptions) {
+		o.userAgent = userAgent
+	}
+}
+
+// Sets the policy for retrying the failed requests, by default the requests
+// aren't retried
+func WithRetryPolicy(policy *RetryPolicy) Option {
+	return func(o *clientOptions) {
+		o.retryPolicy = policy
+	}
+}
+
+// Sets the logger for the requests, by default nothing is logged
+func WithLogger(logger Logger) Option {
+	return func(o *clientOptions) {
+		o.logger = logger
+	}
+}
+
+// Sets the rate limiter that will be waited before every request, by default
+// there is no limit. NewDefaultRateLimiter returns a limiter that adapts to
+// the rate limits of the API
+func WithRateLimiter(rateLimiter RateLimiter) Option {
+	return func(o *clientOptions) {
+		o.rateLimiter = rateLimiter
+	}
+}
+
+// Sets the bandwidth limiter of all the uploads and downloads, by default
+// there is no limit
+func WithBandwidthLimiter(limiter *BandwidthLimiter) Option {
+	return func(o *clientOptions) {
+		o.bandwidth = limiter
+	}
+}
+
+// Creates a new client with the options, the credentials are required. For
+// example:
+//
+//	client, err := copy.New(
+//		copy.WithCredentials(appToken, appSecret, accessToken, accessSecret),
+//		copy.WithRetryPolicy(copy.NewDefaultRetryPolicy()),
+//	)
+func New(opts ...Option) (*Client, error) {
+	o := &clientOptions{
+		httpClient:   defaultHttpClient,
+		resourcesUrl: defaultResourcesUrl,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.httpClient == nil {
+		o.httpClient = defaultHttpClient
+	}
+
+	if o.resourcesUrl == "" {
+		o.resourcesUrl = defaultResourcesUrl
+	}
+
+	session, err := NewSessionWithProvider(o.credentials)
+
+	if err != nil {
+		return nil, fmt.Errorf("Could not create the client, Check access settings: %v", err)
+	}
+
+	session.RetryPolicy = o.retryPolicy
+	session.UserAgent = o.userAgent
+	session.Logger = o.logger
+	session.RateLimiter = o.rateLimiter
+
+	return &Client{
+		session:      session,
+		resourcesUrl: o.resourcesUrl,
+		httpClient:   o.httpClient,
+		bandwidth:    o.bandwidth,
+	}, nil
+}
@@ -0,0 +1,100 @@
+package copy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Counts the calls and fails if needed
+type testRateLimiter struct {
+	calls int
+	err   error
+}
+
+func (l *testRateLimiter) Wait(ctx context.Context) error {
+	l.calls++
+	return l.err
+}
+
+// Tests the client creation with the options
+func TestNewWithOptions(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	logBuf := new(bytes.Buffer)
+	limiter := &testRateLimiter{}
+
+	c, err := New(
+		WithHTTPClient(server.Client()),
+		WithBaseURL(server.URL),
+		WithCredentials(os.Getenv(appTokenEnv), os.Getenv(appSecretEnv),
+			os.Getenv(accessTokenEnv), os.Getenv(accessSecretEnv)),
+		WithUserAgent("go-copy-test/1.0"),
+		WithRetryPolicy(testRetryPolicy()),
+		WithLogger(log.New(logBuf, "", 0)),
+		WithRateLimiter(limiter),
+	)
+
+	if err != nil {
+		t.Fatalf("Error creating a client: %v", err)
+	}
+
+	mux.HandleFunc("/options",
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("User-Agent") != "go-copy-test/1.0" {
+				t.Errorf("Wrong User-Agent: %s", r.Header.Get("User-Agent"))
+			}
+		},
+	)
+
+	if _, err := c.DoRequestDecoding("GET", "options", nil, nil); err != nil {
+		t.Errorf("Shouldn't be an error: %v", err)
+	}
+
+	if limiter.calls != 1 {
+		t.Errorf("Rate limiter should be called before the request")
+	}
+
+	if !strings.Contains(logBuf.String(), "GET "+server.URL+"/options") {
+		t.Errorf("The request should be logged: %s", logBuf.String())
+	}
+
+	// Rate limiter errors stop the requests
+	limiter.err = errors.New("limited")
+	if _, err := c.DoRequestDecoding("GET", "options", nil, nil); !errors.Is(err, limiter.err) {
+		t.Errorf("Should be the rate limiter error: %v", err)
+	}
+}
+
+// Tests the client creation without credentials
+func TestNewWithoutCredentials(t *testing.T) {
+	if _, err := New(WithBaseURL("http://resources/fake")); err == nil {
+		t.Error("Should be an error when creating the client")
+	}
+}
+
+// Tests the defaults of the client
+func TestNewDefaults(t *testing.T) {
+	c, err := New(
+		WithHTTPClient(nil),
+		WithCredentials(os.Getenv(appTokenEnv), os.Getenv(appSecretEnv),
+			os.Getenv(accessTokenEnv), os.Getenv(accessSecretEnv)),
+	)
+
+	if err != nil {
+		t.Fatalf("Error creating a client: %v", err)
+	}
+
+	if c.httpClient != defaultHttpClient || c.resourcesUrl != defaultResourcesUrl ||
+		c.session.RetryPolicy != nil || c.session.RateLimiter != nil {
+		t.Errorf("Wrong defaults")
+	}
+}
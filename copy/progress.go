@@ -0,0 +1,148 @@
+package copy
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProgressFunc receives the bytes transferred and the total bytes of the
+// transfer (-1 if unknown)
+type ProgressFunc func(done, total int64)
+
+// Minimum time between progress calls of the uploads
+const defaultProgressInterval = 100 * time.Millisecond
+
+// Reports the bytes read, at most once per interval. The last read (the
+// total or EOF) is always reported
+type progressReader struct {
+	r        io.Reader
+	done     int64
+	total    int64
+	interval time.Duration
+	progress ProgressFunc
+
+	last     time.Time
+	reported int64
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.done += int64(n)
+
+	finished := err == io.EOF || (p.total >= 0 && p.done >= p.total)
+	now := time.Now()
+
+	if (finished && p.reported != p.done) || (n > 0 && now.Sub(p.last) >= p.interval) {
+		p.last = now
+		p.reported = p.done
+		p.progress(p.done, p.total)
+	}
+
+	return n, err
+}
+
+// ProgressBar draws the progress of a transfer in a line of a terminal:
+//
+//	big.bin [=============>            ]  52%   5.2 MB/10.0 MB   1.3 MB/s
+//
+// Use the Update method as the ProgressFunc of the transfer:
+//
+//	bar := copy.NewProgressBar(os.Stderr, "big.bin")
+//	fs.UploadFileWithOptions(ctx, "big.bin", "backups/big.bin", &copy.UploadOptions{Progress: bar.Update})
+type ProgressBar struct {
+	Out   io.Writer
+	Label string
+
+	// Width of the bar in characters
+	Width int
+
+	mu       sync.Mutex
+	start    time.Time
+	finished bool
+}
+
+// Creates a progress bar that writes on out
+func NewProgressBar(out io.Writer, label string) *ProgressBar {
+	return &ProgressBar{
+		Out:   out,
+		Label: label,
+		Width: 30,
+	}
+}
+
+// Draws the progress, when the transfer finishes ends the line
+func (b *ProgressBar) Update(done, total int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.finished {
+		return
+	}
+
+	now := time.Now()
+	if b.start.IsZero() {
+		b.start = now
+	}
+
+	rate := 0.0
+	if elapsed := now.Sub(b.start).Seconds(); elapsed > 0 {
+		rate = float64(done) / elapsed
+	}
+
+	line := b.Label
+	if total >= 0 {
+		ratio := 1.0
+		if total > 0 {
+			ratio = float64(done) / float64(total)
+		}
+		if ratio > 1 {
+			ratio = 1
+		}
+		line = fmt.Sprintf("%s %s %3.0f%% %10s/%s", line, b.bar(ratio), ratio*100,
+			FormatBytes(done), FormatBytes(total))
+	} else {
+		line = fmt.Sprintf("%s %10s", line, FormatBytes(done))
+	}
+	line = fmt.Sprintf("%s %10s/s", line, FormatBytes(int64(rate)))
+
+	b.finished = total >= 0 && done >= total
+	if b.finished {
+		line += "\n"
+	}
+
+	fmt.Fprint(b.Out, "\r"+line)
+}
+
+func (b *ProgressBar) bar(ratio float64) string {
+	width := b.Width
+	if width <= 0 {
+		width = 30
+	}
+
+	filled := int(ratio * float64(width))
+	bar := strings.Repeat("=", filled)
+	if filled < width {
+		bar += ">" + strings.Repeat(" ", width-filled-1)
+	}
+
+	return "[" + bar + "]"
+}
+
+// Returns the bytes in human units (1.5 MB)
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,129 @@
+package copy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUploadFileWithOptionsProgress(t *testing.T) {
+	setupFileService(t)
+	defer tearDownFileService()
+
+	origFile := bytes.Repeat([]byte("a"), 1024*1024)
+
+	mux.HandleFunc(strings.Join([]string{"", filesTopLevelSuffix, "tests/uploads"}, "/"),
+		func(w http.ResponseWriter, r *http.Request) {
+			io.Copy(ioutil.Discard, r.Body)
+		},
+	)
+
+	tmpFile, _ := ioutil.TempFile("", "go-copy-progress")
+	tmpFile.Write(origFile)
+	tmpFile.Close()
+	defer os.Remove(tmpFile.Name())
+
+	calls := []int64{}
+	opts := &UploadOptions{
+		Progress: func(done, total int64) {
+			if total != int64(len(origFile)) {
+				t.Errorf("Wrong total: %d", total)
+			}
+			calls = append(calls, done)
+		},
+		ProgressInterval: time.Hour,
+	}
+
+	err := fileService.UploadFileWithOptions(context.Background(), tmpFile.Name(), "tests/uploads/big.bin", opts)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// The first read and the last one, the interval limits the rest
+	if len(calls) != 2 || calls[len(calls)-1] != int64(len(origFile)) {
+		t.Errorf("Wrong progress calls: %v", calls)
+	}
+}
+
+func TestProgressReader(t *testing.T) {
+	content := bytes.Repeat([]byte("a"), 100)
+
+	// Unknown size, the EOF is the end
+	calls := []int64{}
+	r := &progressReader{
+		r:        &oneByteReader{bytes.NewReader(content)},
+		total:    -1,
+		progress: func(done, total int64) { calls = append(calls, done) },
+	}
+
+	io.Copy(ioutil.Discard, r)
+
+	if len(calls) != 100 || calls[99] != 100 {
+		t.Errorf("Wrong progress calls: %v", calls)
+	}
+}
+
+// Reads one byte at a time
+type oneByteReader struct {
+	r io.Reader
+}
+
+func (o *oneByteReader) Read(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	return o.r.Read(b[:1])
+}
+
+func TestProgressBar(t *testing.T) {
+	out := new(bytes.Buffer)
+	bar := NewProgressBar(out, "big.bin")
+	bar.Width = 10
+
+	bar.Update(512*1024, 1024*1024)
+	if !strings.Contains(out.String(), "big.bin [=====>    ]  50%   512.0 KB/1.0 MB") {
+		t.Errorf("Wrong progress bar: %q", out.String())
+	}
+
+	bar.Update(1024*1024, 1024*1024)
+	if !strings.HasSuffix(out.String(), "\n") || !strings.Contains(out.String(), "[==========] 100%") {
+		t.Errorf("Wrong finished progress bar: %q", out.String())
+	}
+
+	// Finished bars are not drawn again
+	l := out.Len()
+	bar.Update(1024*1024, 1024*1024)
+	if out.Len() != l {
+		t.Errorf("Finished bar shouldn't be drawn")
+	}
+
+	// Unknown size
+	out.Reset()
+	bar = NewProgressBar(out, "stream")
+	bar.Update(2048, -1)
+	if !strings.Contains(out.String(), "stream     2.0 KB") {
+		t.Errorf("Wrong progress bar: %q", out.String())
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:                      "0 B",
+		1023:                   "1023 B",
+		1024:                   "1.0 KB",
+		1536:                   "1.5 KB",
+		5 * 1024 * 1024 * 1024: "5.0 GB",
+	}
+
+	for n, want := range tests {
+		if got := FormatBytes(n); got != want {
+			t.Errorf("Wrong format of %d: %s, want %s", n, got, want)
+		}
+	}
+}
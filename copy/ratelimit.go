@@ -0,0 +1,200 @@
+package copy
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimiter limits the requests made to the Copy API, Wait is called before
+// every request and blocks until the request can be made or the context is
+// done
+type RateLimiter interface {
+	Wait(ctx context.Context) error
+}
+
+// RateLimitObserver is implemented by the rate limiters that adapt to the
+// responses of the API, Observe is called after every response
+type RateLimitObserver interface {
+	Observe(resp *http.Response)
+}
+
+// Rate limit headers of the responses
+const (
+	rateLimitRemainingHeader = "X-RateLimit-Remaining"
+	rateLimitResetHeader     = "X-RateLimit-Reset"
+)
+
+// TokenBucketRateLimiter allows a rate of requests per second with bursts.
+// It adapts the rate to the responses:
+//
+//   - With the X-RateLimit-Remaining and X-RateLimit-Reset headers the
+//     remaining requests are spread until the reset (never faster than the
+//     configured rate), with no remaining requests waits until the reset
+//   - With a 429 waits the Retry-After and halves the rate, the rate grows
+//     again with the next successful responses
+type TokenBucketRateLimiter struct {
+	mu sync.Mutex
+
+	maxRate float64
+	minRate float64
+	rate    float64
+	burst   float64
+	tokens  float64
+	last    time.Time
+
+	// Nothing is sent until then
+	pausedUntil time.Time
+}
+
+// Creates a limiter of requestsPerSecond with bursts of burst requests
+func NewTokenBucketRateLimiter(requestsPerSecond float64, burst int) *TokenBucketRateLimiter {
+	if requestsPerSecond <= 0 {
+		requestsPerSecond = 1
+	}
+
+	if burst <= 0 {
+		burst = 1
+	}
+
+	return &TokenBucketRateLimiter{
+		maxRate: requestsPerSecond,
+		minRate: requestsPerSecond / 16,
+		rate:    requestsPerSecond,
+		burst:   float64(burst),
+		tokens:  float64(burst),
+		last:    time.Now(),
+	}
+}
+
+// Returns a limiter of 10 requests per second with bursts of 10 requests
+func NewDefaultRateLimiter() *TokenBucketRateLimiter {
+	return NewTokenBucketRateLimiter(10, 10)
+}
+
+func (l *TokenBucketRateLimiter) Wait(ctx context.Context) error {
+	for {
+		wait := l.reserve(time.Now())
+		if wait <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// Takes a token, if there is none returns the time to wait for the next one
+func (l *TokenBucketRateLimiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill(now)
+
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now)
+	}
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+func (l *TokenBucketRateLimiter) refill(now time.Time) {
+	if now.After(l.last) {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+}
+
+// Adapts the rate to the response
+func (l *TokenBucketRateLimiter) Observe(resp *http.Response) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill(now)
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		l.rate = l.rate / 2
+		if l.rate < l.minRate {
+			l.rate = l.minRate
+		}
+		l.tokens = 0
+
+		if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			l.pause(now.Add(wait))
+		}
+		if reset, ok := parseRateLimitReset(resp.Header.Get(rateLimitResetHeader), now); ok {
+			l.pause(reset)
+		}
+		return
+	}
+
+	remaining, err := strconv.Atoi(resp.Header.Get(rateLimitRemainingHeader))
+	reset, ok := parseRateLimitReset(resp.Header.Get(rateLimitResetHeader), now)
+
+	if err != nil || !ok || !reset.After(now) {
+		// Recover the rate slowly after the 429s
+		l.rate += l.maxRate / 10
+		if l.rate > l.maxRate {
+			l.rate = l.maxRate
+		}
+		return
+	}
+
+	if remaining <= 0 {
+		l.tokens = 0
+		l.pause(reset)
+		return
+	}
+
+	// Spread the remaining requests until the reset
+	l.rate = float64(remaining) / reset.Sub(now).Seconds()
+	if l.rate > l.maxRate {
+		l.rate = l.maxRate
+	}
+	if l.rate < l.minRate {
+		l.rate = l.minRate
+	}
+}
+
+func (l *TokenBucketRateLimiter) pause(until time.Time) {
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// Parses the X-RateLimit-Reset header, it could be a unix timestamp or the
+// seconds until the reset
+func parseRateLimitReset(header string, now time.Time) (time.Time, bool) {
+	if header == "" {
+		return time.Time{}, false
+	}
+
+	seconds, err := strconv.ParseInt(header, 10, 64)
+	if err != nil || seconds < 0 {
+		return time.Time{}, false
+	}
+
+	// Before 2001 can't be a timestamp
+	if seconds > 1000000000 {
+		return time.Unix(seconds, 0), true
+	}
+
+	return now.Add(time.Duration(seconds) * time.Second), true
+}
@@ -0,0 +1,110 @@
+package copy
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketRateLimiter(t *testing.T) {
+	limiter := NewTokenBucketRateLimiter(20, 2)
+
+	// The burst and 4 more requests at 20 per second
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond || elapsed > time.Second {
+		t.Errorf("Wrong limited time: %s", elapsed)
+	}
+
+	// No tokens left
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Should be a deadline error: %v", err)
+	}
+}
+
+func rateLimitResponse(status int, headers map[string]string) *http.Response {
+	resp := &http.Response{StatusCode: status, Header: http.Header{}}
+	for k, v := range headers {
+		resp.Header.Set(k, v)
+	}
+	return resp
+}
+
+func TestTokenBucketRateLimiterObserve(t *testing.T) {
+	limiter := NewTokenBucketRateLimiter(10, 10)
+
+	// 429s halve the rate and pause
+	limiter.Observe(rateLimitResponse(http.StatusTooManyRequests, map[string]string{"Retry-After": "2"}))
+
+	if limiter.rate != 5 {
+		t.Errorf("Wrong rate after 429: %f", limiter.rate)
+	}
+
+	if wait := limiter.reserve(time.Now()); wait < time.Second || wait > 2*time.Second {
+		t.Errorf("Should wait the Retry-After: %s", wait)
+	}
+
+	// The successful responses recover the rate
+	limiter.pausedUntil = time.Time{}
+	for i := 0; i < 10; i++ {
+		limiter.Observe(rateLimitResponse(http.StatusOK, nil))
+	}
+
+	if limiter.rate != 10 {
+		t.Errorf("Rate should be recovered: %f", limiter.rate)
+	}
+
+	// The remaining requests are spread until the reset
+	limiter.Observe(rateLimitResponse(http.StatusOK, map[string]string{
+		rateLimitRemainingHeader: "20",
+		rateLimitResetHeader:     "10",
+	}))
+
+	if limiter.rate < 1.9 || limiter.rate > 2.1 {
+		t.Errorf("Wrong rate with rate limit headers: %f", limiter.rate)
+	}
+
+	// No remaining requests, wait until the reset (timestamp)
+	reset := time.Now().Add(5 * time.Second).Unix()
+	limiter.Observe(rateLimitResponse(http.StatusOK, map[string]string{
+		rateLimitRemainingHeader: "0",
+		rateLimitResetHeader:     strconv.FormatInt(reset, 10),
+	}))
+
+	if wait := limiter.reserve(time.Now()); wait < 3*time.Second || wait > 5*time.Second {
+		t.Errorf("Should wait until the reset: %s", wait)
+	}
+}
+
+func TestRateLimiterObservesResponses(t *testing.T) {
+	setup(t)
+	defer tearDown()
+
+	limiter := NewTokenBucketRateLimiter(10, 10)
+	client.SetRateLimiter(limiter)
+	defer client.SetRateLimiter(nil)
+
+	mux.HandleFunc("/rate-limited",
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		},
+	)
+
+	if _, err := client.DoRequestDecoding("GET", "rate-limited", nil, nil); err == nil {
+		t.Errorf("Should be an error")
+	}
+
+	if limiter.rate != 5 || limiter.pausedUntil.IsZero() {
+		t.Errorf("The limiter should observe the 429")
+	}
+}
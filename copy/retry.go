@@ -0,0 +1,147 @@
+package copy
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy configures how the failed requests are retried. The requests
+// are retried when the transport fails or when the response has a retryable
+// status code, only if the method is retryable and the body of the request
+// can be rewinded.
+//
+// The wait between attempts grows exponentially from MinBackoff to
+// MaxBackoff, if the response has a Retry-After header it will be used
+// instead
+type RetryPolicy struct {
+	// Maximum number of attempts (the first one included)
+	MaxAttempts int
+
+	// Backoff limits between attempts
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// Randomization factor of the backoff [0, 1], with 0.5 the backoff will
+	// be between 50% and 100% of the exponential backoff
+	Jitter float64
+
+	// Status codes and methods that will be retried
+	RetryableStatusCodes []int
+	RetryableMethods     []string
+}
+
+// Returns a retry policy that retries the idempotent requests 3 times on
+// connection errors, 429s and 5xx
+func NewDefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 3,
+		MinBackoff:  500 * time.Millisecond,
+		MaxBackoff:  30 * time.Second,
+		Jitter:      0.5,
+		RetryableStatusCodes: []int{
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+		RetryableMethods: []string{"GET", "HEAD", "OPTIONS", "PUT", "DELETE"},
+	}
+}
+
+// Checks if the request needs to be retried after the attempt. A nil policy
+// never retries
+func (p *RetryPolicy) shouldRetry(req *http.Request, resp *http.Response, err error, attempt int) bool {
+	if p == nil || attempt >= p.MaxAttempts {
+		return false
+	}
+
+	// Cancelled by the caller, don't insist
+	if req.Context().Err() != nil || errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	// We can't send the body again
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	if !p.retryableMethod(req.Method) {
+		return false
+	}
+
+	// Connection resets, timeouts...
+	if err != nil {
+		return true
+	}
+
+	return p.retryableStatusCode(resp.StatusCode)
+}
+
+func (p *RetryPolicy) retryableMethod(method string) bool {
+	for _, m := range p.RetryableMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *RetryPolicy) retryableStatusCode(code int) bool {
+	for _, c := range p.RetryableStatusCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the time to wait before the next attempt
+func (p *RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return wait
+		}
+	}
+
+	wait := float64(p.MinBackoff) * math.Pow(2, float64(attempt-1))
+	if p.MaxBackoff > 0 && wait > float64(p.MaxBackoff) {
+		wait = float64(p.MaxBackoff)
+	}
+
+	if p.Jitter > 0 {
+		wait -= wait * p.Jitter * rand.Float64()
+	}
+
+	return time.Duration(wait)
+}
+
+// Parses the Retry-After header, it could be the seconds or an HTTP date
+func parseRetryAfter(header string) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(header); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+
+	return 0, false
+}
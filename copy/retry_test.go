@@ -0,0 +1,239 @@
+package copy
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Returns a fast retry policy for the tests
+func testRetryPolicy() *RetryPolicy {
+	policy := NewDefaultRetryPolicy()
+	policy.MinBackoff = time.Millisecond
+	policy.MaxBackoff = 10 * time.Millisecond
+	return policy
+}
+
+// Checks that the retryable status codes are retried
+func TestRetryStatusCodes(t *testing.T) {
+	setup(t)
+	defer tearDown()
+
+	client.SetRetryPolicy(testRetryPolicy())
+
+	attempts := 0
+	mux.HandleFunc("/retry",
+		func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+
+			if r.Header.Get("Authorization") == "" {
+				t.Errorf("Every attempt should be signed")
+			}
+
+			if attempts < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(`{"field1": "testfield1"}`))
+		},
+	)
+
+	obj := new(testObject)
+	if _, err := client.DoRequestDecoding("GET", "retry", nil, obj); err != nil {
+		t.Errorf("Shouldn't be an error: %v", err)
+	}
+
+	if attempts != 3 || obj.Field1 != "testfield1" {
+		t.Errorf("Wrong retries, attempts: %d", attempts)
+	}
+
+	// Max attempts reached
+	attempts = -10
+	if _, err := client.DoRequestDecoding("GET", "retry", nil, obj); err == nil {
+		t.Errorf("Max attempts reached, should be an error")
+	}
+
+	if attempts != -7 {
+		t.Errorf("Wrong retries, should make 3 attempts")
+	}
+}
+
+// Checks that the not retryable methods and status codes aren't retried
+func TestRetryNotRetryable(t *testing.T) {
+	setup(t)
+	defer tearDown()
+
+	client.SetRetryPolicy(testRetryPolicy())
+
+	attempts := 0
+	mux.HandleFunc("/retry",
+		func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			if r.Method == "GET" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+		},
+	)
+
+	client.DoRequestDecoding("POST", "retry", nil, nil)
+	client.DoRequestDecoding("GET", "retry", nil, nil)
+
+	if attempts != 2 {
+		t.Errorf("POST and 404 shouldn't be retried, attempts: %d", attempts)
+	}
+
+	// Without policy
+	attempts = 0
+	client.SetRetryPolicy(nil)
+	client.DoRequestDecoding("PUT", "retry", nil, nil)
+
+	if attempts != 1 {
+		t.Errorf("Without policy shouldn't be retried, attempts: %d", attempts)
+	}
+}
+
+// Checks that the Retry-After header is used instead of the backoff
+func TestRetryAfter(t *testing.T) {
+	setup(t)
+	defer tearDown()
+
+	policy := testRetryPolicy()
+	policy.MinBackoff = time.Hour
+	policy.MaxBackoff = time.Hour
+	client.SetRetryPolicy(policy)
+
+	attempts := 0
+	mux.HandleFunc("/retry",
+		func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			if attempts == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+			}
+		},
+	)
+
+	done := make(chan error)
+	go func() {
+		_, err := client.DoRequestDecoding("GET", "retry", nil, nil)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil || attempts != 2 {
+			t.Errorf("Should be retried once without error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Retry-After header wasn't used")
+	}
+}
+
+// Checks that the bodies are rewinded for the next attempt
+func TestRetryRewindBody(t *testing.T) {
+	setup(t)
+	defer tearDown()
+
+	client.SetRetryPolicy(testRetryPolicy())
+
+	origFile := bytes.Repeat([]byte("0123456789abcdef"), 64*1024) // 1MB
+
+	attempts := 0
+	mux.HandleFunc("/retry",
+		func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+
+			// Fail in the middle of the first upload
+			if attempts == 1 {
+				r.Body.Read(make([]byte, 1024))
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+
+			r.ParseMultipartForm(100000)
+			if r.MultipartForm != nil {
+				file, _ := r.MultipartForm.File["file"][0].Open()
+				defer file.Close()
+
+				content, _ := ioutil.ReadAll(file)
+				if !bytes.Equal(origFile, content) {
+					t.Errorf("contents are not equal")
+				}
+				return
+			}
+
+			r.ParseForm()
+			if r.PostForm.Get("field1") != "value1" {
+				t.Errorf("Wrong form body")
+			}
+		},
+	)
+
+	// Multipart
+	_, err := client.DoRequestMultipartReader(bytes.NewReader(origFile), int64(len(origFile)), "retry", "file.bin", "PUT")
+	if err != nil || attempts != 2 {
+		t.Errorf("Should be retried once without error: %v", err)
+	}
+
+	// Form
+	attempts = 0
+	_, err = client.DoRequestDecoding("PUT", "retry", map[string][]string{"field1": {"value1"}}, nil)
+	if err != nil || attempts != 2 {
+		t.Errorf("Should be retried once without error: %v", err)
+	}
+
+	// Not seekable readers can't be retried
+	attempts = 0
+	_, err = client.DoRequestMultipartReader(ioutil.NopCloser(strings.NewReader("test")), 4, "retry", "file.bin", "PUT")
+	if err == nil || attempts != 1 {
+		t.Errorf("Not seekable body shouldn't be retried, attempts: %d", attempts)
+	}
+}
+
+// Checks the backoff calculation
+func TestRetryBackoff(t *testing.T) {
+	policy := &RetryPolicy{
+		MinBackoff: time.Second,
+		MaxBackoff: 10 * time.Second,
+		Jitter:     0.5,
+	}
+
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{10, 10 * time.Second},
+	}
+
+	for _, test := range tests {
+		wait := policy.backoff(test.attempt, nil)
+		if wait > test.max || wait < test.max/2 {
+			t.Errorf("Wrong backoff for attempt %d: %v", test.attempt, wait)
+		}
+	}
+
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Retry-After", "120")
+	if wait := policy.backoff(1, resp); wait != 2*time.Minute {
+		t.Errorf("Wrong Retry-After backoff: %v", wait)
+	}
+
+	resp.Header.Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	if wait := policy.backoff(1, resp); wait < 59*time.Minute || wait > time.Hour {
+		t.Errorf("Wrong Retry-After date backoff: %v", wait)
+	}
+
+	resp.Header.Set("Retry-After", "wrong")
+	if wait := policy.backoff(1, resp); wait > time.Second {
+		t.Errorf("Wrong Retry-After should use the backoff: %v", wait)
+	}
+}
@@ -0,0 +1,57 @@
+package copy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Returns the content of a past revision of the file, the revision is the
+// modified time of the revision (see ListRevisionsMeta). The user NEEDS TO
+// CLOSE the returned readcloser
+//
+// https://www.copy.com/developer/documentation#api-calls/filesystem
+func (fs *FileService) GetFileRevision(ctx context.Context, path string, revision int) (io.ReadCloser, error) {
+	resp, err := fs.getFileRevision(ctx, path, revision)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// Returns the response with the content of a revision, the body must be
+// closed
+func (fs *FileService) getFileRevision(ctx context.Context, path string, revision int) (*http.Response, error) {
+	path = strings.Trim(path, "/")
+	return fs.client.DoRequestContentContext(ctx, fmt.Sprintf(filesRevisionSuffix, path, revision), nil)
+}
+
+// Makes the revision of the file the current one, the content of the
+// revision is uploaded as a new revision so the history is kept
+func (fs *FileService) RestoreRevision(ctx context.Context, path string, revision int) error {
+	path = strings.Trim(path, "/")
+
+	resp, err := fs.getFileRevision(ctx, path, revision)
+
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// The upload is sent with the size, if the download doesn't have it it's
+	// taken from the metadata of the revision
+	size := resp.ContentLength
+	if size < 0 {
+		meta, err := fs.GetRevisionMetaContext(ctx, path, revision)
+		if err != nil {
+			return err
+		}
+		size = int64(meta.Size)
+	}
+
+	return fs.UpdateReaderContext(ctx, resp.Body, size, path, nil)
+}
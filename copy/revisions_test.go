@@ -0,0 +1,109 @@
+package copy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"testing"
+)
+
+func TestGetFileRevision(t *testing.T) {
+	setupFileService(t)
+	defer tearDownFileService()
+
+	mux.HandleFunc("/"+fmt.Sprintf(filesRevisionSuffix, "docs/notes.txt", 1365532651),
+		func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, "GET")
+			fmt.Fprint(w, "old content")
+		})
+
+	r, err := fileService.GetFileRevision(context.Background(), "/docs/notes.txt", 1365532651)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer r.Close()
+
+	data, _ := ioutil.ReadAll(r)
+	if string(data) != "old content" {
+		t.Errorf("Wrong revision content: %q", data)
+	}
+
+	if _, err := fileService.GetFileRevision(context.Background(), "docs/notes.txt", 1); !IsNotFound(err) {
+		t.Errorf("Missing revision should be not found: %v", err)
+	}
+}
+
+func TestRestoreRevision(t *testing.T) {
+	setupFileService(t)
+	defer tearDownFileService()
+
+	chunked := false
+	mux.HandleFunc("/"+fmt.Sprintf(filesRevisionSuffix, "docs/notes.txt", 1365532651),
+		func(w http.ResponseWriter, r *http.Request) {
+			// Without Content-Length
+			if chunked {
+				w.(http.Flusher).Flush()
+			}
+			fmt.Fprint(w, "old content")
+		})
+
+	mux.HandleFunc("/"+fmt.Sprintf(revisionSuffix, "docs/notes.txt", 1365532651),
+		func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, "GET")
+			fmt.Fprint(w, `{"path":"/docs/notes.txt", "type":"file", "size":"11"}`)
+		})
+
+	restored := ""
+	mux.HandleFunc("/files/docs/notes.txt", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "PUT")
+
+		body, _ := ioutil.ReadAll(r.Body)
+		if len(r.TransferEncoding) != 0 || r.ContentLength != int64(len(body)) {
+			t.Errorf("Wrong upload length: %d, %d bytes sent %v", r.ContentLength, len(body), r.TransferEncoding)
+		}
+
+		_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		form, err := multipart.NewReader(bytes.NewReader(body), params["boundary"]).ReadForm(1 << 20)
+		if err != nil || len(form.File["file"]) != 1 {
+			t.Fatalf("Wrong upload: %v", err)
+		}
+
+		header := form.File["file"][0]
+		file, _ := header.Open()
+		data, _ := ioutil.ReadAll(file)
+		restored = header.Filename + ":" + string(data)
+		fmt.Fprint(w, `{}`)
+	})
+
+	if err := fileService.RestoreRevision(context.Background(), "docs/notes.txt", 1365532651); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if restored != "notes.txt:old content" {
+		t.Errorf("Wrong restored content: %q", restored)
+	}
+
+	// The size from the metadata of the revision
+	restored = ""
+	chunked = true
+	if err := fileService.RestoreRevision(context.Background(), "/docs/notes.txt", 1365532651); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if restored != "notes.txt:old content" {
+		t.Errorf("Wrong restored content: %q", restored)
+	}
+
+	// Nothing is uploaded if the revision can't be read
+	restored = ""
+	if err := fileService.RestoreRevision(context.Background(), "docs/notes.txt", 1); !IsNotFound(err) {
+		t.Errorf("Missing revision should be not found: %v", err)
+	}
+	if restored != "" {
+		t.Error("Nothing should be restored")
+	}
+}
@@ -1,10 +1,16 @@
 package copy
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/garyburd/go-oauth/oauth"
 )
@@ -27,6 +33,26 @@ type AppToken struct {
 type Session struct {
 	OauthClient oauth.Client
 	TokenCreds  oauth.Credentials
+
+	// The policy for retrying the failed requests, nil means no retries
+	RetryPolicy *RetryPolicy
+
+	// Optional User-Agent header for the requests
+	UserAgent string
+
+	// Optional logger for the requests
+	Logger Logger
+
+	// Optional rate limiter, waited before every request. If it is a
+	// RateLimitObserver it receives every response
+	RateLimiter RateLimiter
+
+	// Optional provider of the credentials, after a 401 the credentials are
+	// asked again and the request is retried once if they changed
+	Provider CredentialsProvider
+
+	// Guards the credentials when they are refreshed
+	mu sync.Mutex
 }
 
 // Creates a new Ouath session for making requests
@@ -62,40 +88,102 @@ func NewSession(appToken AppToken, accessToken AccessToken) (*Session, error) {
 
 }
 
+// Creates a new Oauth session with the credentials of the provider
+func NewSessionWithProvider(provider CredentialsProvider) (*Session, error) {
+	if provider == nil {
+		return nil, errors.New("Could not create the session, Missing credentials provider")
+	}
+
+	creds, err := provider.Credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := NewSession(creds.AppToken, creds.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	session.Provider = provider
+	return session, nil
+}
+
 func (s *Session) Get(urlStr string, form url.Values, httpClient *http.Client) (*http.Response, error) {
-	req, err := http.NewRequest("GET", urlStr, nil)
+	return s.GetContext(context.Background(), urlStr, form, httpClient)
+}
+
+// Like Get but the request is made with the context, the request will be
+// cancelled when the context is done
+func (s *Session) GetContext(ctx context.Context, urlStr string, form url.Values, httpClient *http.Client) (*http.Response, error) {
+	req, err := newGetRequest(ctx, urlStr, form)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Do(req, httpClient)
+
+}
+
+// Like GetContext but only asks for the bytes between start and end (both
+// included) with the Range header, a negative end means until the end. The
+// server could ignore the range, check the response status (206 or 200)
+func (s *Session) GetRangeContext(ctx context.Context, urlStr string, form url.Values, start, end int64, httpClient *http.Client) (*http.Response, error) {
+	req, err := newGetRequest(ctx, urlStr, form)
 	if err != nil {
 		return nil, err
 	}
 
-	u := req.URL
+	if end < 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", start))
+	} else {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	}
+
+	return s.Do(req, httpClient)
+}
+
+// Creates a GET request with the form in the query
+func newGetRequest(ctx context.Context, urlStr string, form url.Values) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
+	if err != nil {
+		return nil, err
+	}
 
 	if req.URL.RawQuery != "" { // This is needed for the oauth  auth
 
 		return nil, errors.New("oauth: url must not contain a query string")
 	}
 
-	req.Header.Set("Authorization", s.OauthClient.AuthorizationHeader(&s.TokenCreds, "GET", u, form))
+	// The form will be signed with the URL query
 	req.URL.RawQuery = form.Encode()
 
-	return s.Do(req, httpClient)
-
+	return req, nil
 }
 
 func (s *Session) Post(urlStr string, form url.Values, httpClient *http.Client) (*http.Response, error) {
-	req, err := http.NewRequest("POST", urlStr, strings.NewReader(form.Encode()))
+	return s.PostContext(context.Background(), urlStr, form, httpClient)
+}
+
+// Like Post but the request is made with the context, the request will be
+// cancelled when the context is done
+func (s *Session) PostContext(ctx context.Context, urlStr string, form url.Values, httpClient *http.Client) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", urlStr, strings.NewReader(form.Encode()))
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	// Do not send the body so, last param is nil
-	req.Header.Set("Authorization", s.OauthClient.AuthorizationHeader(&s.TokenCreds, "POST", req.URL, nil))
 	return s.Do(req, httpClient)
 }
 
 func (s *Session) Delete(urlStr string, form url.Values, httpClient *http.Client) (*http.Response, error) {
-	req, err := http.NewRequest("DELETE", urlStr, nil)
+	return s.DeleteContext(context.Background(), urlStr, form, httpClient)
+}
+
+// Like Delete but the request is made with the context, the request will be
+// cancelled when the context is done
+func (s *Session) DeleteContext(ctx context.Context, urlStr string, form url.Values, httpClient *http.Client) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", urlStr, nil)
 
 	if err != nil {
 		return nil, err
@@ -105,24 +193,31 @@ func (s *Session) Delete(urlStr string, form url.Values, httpClient *http.Client
 		return nil, errors.New("oauth: url must not contain a query string")
 	}
 
-	req.Header.Set("Authorization", s.OauthClient.AuthorizationHeader(&s.TokenCreds, "DELETE", req.URL, form))
+	// The form will be signed with the URL query
 	req.URL.RawQuery = form.Encode()
 
 	return s.Do(req, httpClient)
 }
 
 func (s *Session) Put(urlStr string, form url.Values, httpClient *http.Client) (*http.Response, error) {
-	req, err := http.NewRequest("PUT", urlStr, strings.NewReader(form.Encode()))
+	return s.PutContext(context.Background(), urlStr, form, httpClient)
+}
+
+// Like Put but the request is made with the context, the request will be
+// cancelled when the context is done
+func (s *Session) PutContext(ctx context.Context, urlStr string, form url.Values, httpClient *http.Client) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "PUT", urlStr, strings.NewReader(form.Encode()))
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	// Do not send the body so, last param is nil
-	req.Header.Set("Authorization", s.OauthClient.AuthorizationHeader(&s.TokenCreds, "PUT", req.URL, nil))
 	return s.Do(req, httpClient)
 }
 
+// Signs and makes the request, if the session has a retry policy the failed
+// requests will be retried (signing them again and rewinding the body). With
+// a credentials provider the 401s are retried once with the new credentials
 func (s *Session) Do(request *http.Request, httpClient *http.Client) (*http.Response, error) {
 
 	// Custom headers for Copy API, [IMPORTANT!!]
@@ -132,9 +227,153 @@ func (s *Session) Do(request *http.Request, httpClient *http.Client) (*http.Resp
 	}
 
 	for k, v := range customHeaders {
-		request.Header.Add(k, v)
+		request.Header.Set(k, v)
+	}
+
+	if s.UserAgent != "" {
+		request.Header.Set("User-Agent", s.UserAgent)
+	}
+
+	refreshed := false
+
+	for attempt := 1; ; attempt++ {
+
+		if s.RateLimiter != nil {
+			if err := s.RateLimiter.Wait(request.Context()); err != nil {
+				return nil, err
+			}
+		}
+
+		// Sign on every attempt, Oauth nonce and timestamp need to be fresh.
+		// Do not send the body so, last param is nil (the query of the URL
+		// is signed)
+		oauthClient, tokenCreds := s.credentials()
+		request.Header.Set("Authorization", oauthClient.AuthorizationHeader(&tokenCreds, request.Method, request.URL, nil))
+
+		resp, err := httpClient.Do(request)
+
+		if err != nil {
+			s.logf("%s %s (attempt %d): %v", request.Method, request.URL, attempt, err)
+		} else {
+			s.logf("%s %s (attempt %d): %d", request.Method, request.URL, attempt, resp.StatusCode)
+
+			if observer, ok := s.RateLimiter.(RateLimitObserver); ok {
+				observer.Observe(resp)
+			}
+		}
+
+		// The tokens could be rotated
+		if err == nil && resp.StatusCode == http.StatusUnauthorized && !refreshed &&
+			(request.Body == nil || request.GetBody != nil) &&
+			s.refreshCredentials(oauthClient.Credentials, tokenCreds) {
+
+			refreshed = true
+			s.logf("%s %s: retrying with the new credentials", request.Method, request.URL)
+
+			io.CopyN(ioutil.Discard, resp.Body, 4096)
+			resp.Body.Close()
+
+			if err := rewindBody(request); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if !s.RetryPolicy.shouldRetry(request, resp, err, attempt) {
+			return resp, err
+		}
+
+		wait := s.RetryPolicy.backoff(attempt, resp)
+		s.logf("%s %s: retrying in %v", request.Method, request.URL, wait)
+
+		// Discard this attempt
+		if resp != nil {
+			io.CopyN(ioutil.Discard, resp.Body, 4096)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-request.Context().Done():
+			timer.Stop()
+			return nil, request.Context().Err()
+		case <-timer.C:
+		}
+
+		// Rewind the body for the next attempt
+		if err := rewindBody(request); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Creates the body of the request again from the start (if it can)
+func rewindBody(request *http.Request) error {
+	if request.GetBody == nil {
+		return nil
+	}
+
+	if request.Body != nil {
+		request.Body.Close()
+	}
+
+	body, err := request.GetBody()
+	if err != nil {
+		return err
 	}
 
-	return httpClient.Do(request)
+	request.Body = body
+	return nil
+}
+
+// Returns the oauth client and the user credentials for signing a request
+func (s *Session) credentials() (oauth.Client, oauth.Credentials) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.OauthClient, s.TokenCreds
+}
+
+// Asks the provider for the credentials after a 401 with the used ones,
+// returns true if they changed (another request could have changed them
+// already)
+func (s *Session) refreshCredentials(appCreds, tokenCreds oauth.Credentials) bool {
+	if s.Provider == nil {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.OauthClient.Credentials != appCreds || s.TokenCreds != tokenCreds {
+		return true
+	}
+
+	creds, err := s.Provider.Credentials()
+	if err != nil {
+		s.logf("Could not refresh the credentials: %v", err)
+		return false
+	}
+
+	newAppCreds := oauth.Credentials{Token: creds.AppToken.Token, Secret: creds.AppToken.Key}
+	newTokenCreds := oauth.Credentials{Token: creds.AccessToken.Token, Secret: creds.AccessToken.Key}
+	if newAppCreds == appCreds && newTokenCreds == tokenCreds {
+		return false
+	}
+
+	s.OauthClient.Credentials = newAppCreds
+	s.TokenCreds = newTokenCreds
+	return true
+}
+
+// Logs with the session logger (if any)
+func (s *Session) logf(format string, v ...interface{}) {
+	if s.Logger != nil {
+		s.Logger.Printf("copy: "+format, v...)
+	}
+}
 
+// Like Do but the request is made with the context, the request will be
+// cancelled when the context is done
+func (s *Session) DoContext(ctx context.Context, request *http.Request, httpClient *http.Client) (*http.Response, error) {
+	return s.Do(request.WithContext(ctx), httpClient)
 }
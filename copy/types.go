@@ -0,0 +1,116 @@
+package copy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Timestamp is a time of the API (unix seconds). Copy sends them as JSON
+// numbers or strings, both are accepted (and RFC 3339 strings). 0, null and
+// "" are the zero time
+type Timestamp struct {
+	time.Time
+
+	// The value as sent by Copy without the quotes ("1365532651"), the
+	// value of the old int and string fields
+	Raw string
+}
+
+// Returns the timestamp of the unix time in seconds, 0 is the zero time
+func NewTimestamp(seconds int64) Timestamp {
+	if seconds == 0 {
+		return Timestamp{Raw: "0"}
+	}
+	return Timestamp{Time: time.Unix(seconds, 0), Raw: strconv.FormatInt(seconds, 10)}
+}
+
+// Returns the unix time in seconds, 0 for the zero time (like the old int
+// fields)
+func (t Timestamp) Seconds() int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	raw, err := unquoteNumber(data)
+	if err != nil {
+		return fmt.Errorf("Wrong timestamp %s: %v", data, err)
+	}
+
+	if raw == "" {
+		*t = Timestamp{}
+		return nil
+	}
+
+	if seconds, err := strconv.ParseFloat(raw, 64); err == nil {
+		*t = NewTimestamp(int64(seconds))
+		t.Raw = raw
+		return nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return fmt.Errorf("Wrong timestamp %s", data)
+	}
+
+	*t = Timestamp{Time: parsed, Raw: raw}
+	return nil
+}
+
+// Encodes the timestamp as unix seconds
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatInt(t.Seconds(), 10)), nil
+}
+
+// Size is a size in bytes of the API, Copy sends them as JSON numbers or
+// strings, both are accepted
+type Size int64
+
+func (s *Size) UnmarshalJSON(data []byte) error {
+	raw, err := unquoteNumber(data)
+	if err != nil {
+		return fmt.Errorf("Wrong size %s: %v", data, err)
+	}
+
+	if raw == "" {
+		*s = 0
+		return nil
+	}
+
+	n, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		// Big sizes could come in exponent notation
+		f, ferr := strconv.ParseFloat(raw, 64)
+		if ferr != nil {
+			return fmt.Errorf("Wrong size %s", data)
+		}
+		n = int64(f)
+	}
+
+	*s = Size(n)
+	return nil
+}
+
+// Returns the JSON number or string without the quotes, null is empty
+func unquoteNumber(data []byte) (string, error) {
+	data = bytes.TrimSpace(data)
+
+	if bytes.Equal(data, []byte("null")) {
+		return "", nil
+	}
+
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return "", err
+		}
+		return s, nil
+	}
+
+	return string(data), nil
+}
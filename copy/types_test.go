@@ -0,0 +1,101 @@
+package copy
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTimestampUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		data    string
+		seconds int64
+		raw     string
+	}{
+		{`1365543105`, 1365543105, "1365543105"},
+		{`"1365543105"`, 1365543105, "1365543105"},
+		{`1365543105.0`, 1365543105, "1365543105.0"},
+		{`"2013-04-09T21:31:45Z"`, 1365543105, "2013-04-09T21:31:45Z"},
+		{`0`, 0, "0"},
+		{`null`, 0, ""},
+		{`""`, 0, ""},
+	}
+
+	for _, test := range tests {
+		var ts Timestamp
+		if err := json.Unmarshal([]byte(test.data), &ts); err != nil {
+			t.Errorf("Wrong timestamp %s: %v", test.data, err)
+			continue
+		}
+
+		if ts.Seconds() != test.seconds {
+			t.Errorf("Wrong seconds of %s: %d", test.data, ts.Seconds())
+		}
+
+		if ts.Raw != test.raw {
+			t.Errorf("Wrong raw value of %s: %q", test.data, ts.Raw)
+		}
+
+		if test.seconds == 0 && !ts.IsZero() {
+			t.Errorf("Should be the zero time: %s", test.data)
+		}
+	}
+
+	var ts Timestamp
+	if err := json.Unmarshal([]byte(`"yesterday"`), &ts); err == nil {
+		t.Error("Should be an error")
+	}
+}
+
+func TestTimestampMarshalJSON(t *testing.T) {
+	meta := Meta{ModifiedTime: NewTimestamp(1365543105)}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var result Meta
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !result.ModifiedTime.Equal(time.Unix(1365543105, 0)) || result.ModifiedTime.Raw != "1365543105" {
+		t.Errorf("Wrong modified time: %v", result.ModifiedTime)
+	}
+
+	if result.DateLastSynced.Seconds() != 0 {
+		t.Errorf("Wrong last synced time: %v", result.DateLastSynced)
+	}
+}
+
+func TestSizeUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		data string
+		size Size
+	}{
+		{`1024`, 1024},
+		{`"1024"`, 1024},
+		{`5368709120`, 5368709120},
+		{`"5368709120"`, 5368709120},
+		{`5.36870912e+09`, 5368709120},
+		{`null`, 0},
+		{`""`, 0},
+	}
+
+	for _, test := range tests {
+		var s Size
+		if err := json.Unmarshal([]byte(test.data), &s); err != nil {
+			t.Errorf("Wrong size %s: %v", test.data, err)
+			continue
+		}
+
+		if s != test.size {
+			t.Errorf("Wrong size of %s: %d", test.data, s)
+		}
+	}
+
+	var s Size
+	if err := json.Unmarshal([]byte(`"big"`), &s); err == nil {
+		t.Error("Should be an error")
+	}
+}
@@ -1,19 +1,20 @@
 package copy
 
 import (
+	"context"
 	"net/url"
 )
 
 // User represents the current user at Copy
 type User struct {
-	Id          string  `json:"id,omitempty"`
-	FirstName   string  `json:"first_name,omitempty"`
-	LastName    string  `json:"last_name,omitempty"`
-	Developer   bool    `json:"developer,omitempty"`
-	CreatedTime int     `json:"created_time,omitempty"`
-	Email       string  `json:"email,omitempty"`
-	Emails      []Email `json:"emails,omitempty"`
-	Storage     Storage `json:"storage,omitempty"`
+	Id          string    `json:"id,omitempty"`
+	FirstName   string    `json:"first_name,omitempty"`
+	LastName    string    `json:"last_name,omitempty"`
+	Developer   bool      `json:"developer,omitempty"`
+	CreatedTime Timestamp `json:"created_time,omitempty"`
+	Email       string    `json:"email,omitempty"`
+	Emails      []Email   `json:"emails,omitempty"`
+	Storage     Storage   `json:"storage,omitempty"`
 }
 
 type Email struct {
@@ -24,9 +25,9 @@ type Email struct {
 }
 
 type Storage struct {
-	Used  int `json:"used,omitempty"`
-	Quota int `json:"quota,omitempty"`
-	Saved int `json:"saved,omitempty"`
+	Used  Size `json:"used,omitempty"`
+	Quota Size `json:"quota,omitempty"`
+	Saved Size `json:"saved,omitempty"`
 }
 
 type UserService struct {
@@ -47,8 +48,13 @@ func NewUserService(client *Client) *UserService {
 //
 //https://www.copy.com/developer/documentation#api-calls/profile
 func (us *UserService) Get() (*User, error) {
+	return us.GetContext(context.Background())
+}
+
+// Like Get but the request is made with the context
+func (us *UserService) GetContext(ctx context.Context) (*User, error) {
 	user := new(User)
-	_, err := us.client.DoRequestDecoding("GET", endpointSuffix, nil, user)
+	_, err := us.client.DoRequestDecodingContext(ctx, "GET", endpointSuffix, nil, user)
 
 	if err != nil {
 		return nil, err
@@ -61,6 +67,11 @@ func (us *UserService) Get() (*User, error) {
 //
 //https://www.copy.com/developer/documentation#api-calls/profile
 func (us *UserService) Update(user *User) error {
+	return us.UpdateContext(context.Background(), user)
+}
+
+// Like Update but the request is made with the context
+func (us *UserService) UpdateContext(ctx context.Context, user *User) error {
 
 	// Prepare the parameters to update (For now only frist and last name,
 	// see copy docs)
@@ -71,7 +82,7 @@ func (us *UserService) Update(user *User) error {
 		"last_name":  {user.LastName},
 	}
 
-	_, err := us.client.DoRequestDecoding("PUT", endpointSuffix, values, user)
+	_, err := us.client.DoRequestDecodingContext(ctx, "PUT", endpointSuffix, values, user)
 
 	if err != nil {
 		return err
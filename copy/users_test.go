@@ -69,7 +69,7 @@ func TestGetUser(t *testing.T) {
 		FirstName:   "Thomas",
 		LastName:    "Hunter",
 		Developer:   true,
-		CreatedTime: 1358175510,
+		CreatedTime: NewTimestamp(1358175510),
 		Email:       "thomashunter@example.com",
 		Emails: []Email{
 			Email{Primary: true,
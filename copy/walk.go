@@ -0,0 +1,250 @@
+package copy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// SkipDir is returned by a WalkFunc for skipping the directory
+var SkipDir = errors.New("skip this directory")
+
+// WalkFunc is called by Walk for each file and directory (the root
+// included). The path is the remote path of the file, if err is not nil the
+// listing of the directory at path failed (meta will be nil if the root
+// failed). Returning SkipDir skips the directory, or the rest of the files of
+// the directory if returned from a file, any other error stops the walk
+type WalkFunc func(path string, meta *Meta, err error) error
+
+// Walk options
+type WalkOptions struct {
+	// Maximum depth to walk, the root is depth 0, 0 means no limit
+	MaxDepth int
+
+	// Number of directories fetched at the same time, it is also the number
+	// of subdirectories of each directory fetched ahead of the walk
+	Concurrency int
+}
+
+const (
+	defaultWalkConcurrency = 4
+)
+
+// Checks if the meta is a directory like object (dirs, root, copy...)
+func (m *Meta) IsDir() bool {
+	return m.Type != "file"
+}
+
+// Walks the file tree rooted at root in lexical order by name (like
+// filepath.WalkDir) calling fn for each file and directory. Subdirectories
+// are fetched concurrently, but fn is called always from the same goroutine
+func (fs *FileService) Walk(ctx context.Context, root string, fn WalkFunc) error {
+	return fs.WalkWithOptions(ctx, root, nil, fn)
+}
+
+// Like Walk but with options
+func (fs *FileService) WalkWithOptions(ctx context.Context, root string, opts *WalkOptions, fn WalkFunc) error {
+	if opts == nil {
+		opts = &WalkOptions{}
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultWalkConcurrency
+	}
+
+	// Stop the pending fetches when the walk ends
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	w := &walker{
+		fs:       fs,
+		ctx:      ctx,
+		fn:       fn,
+		maxDepth: opts.MaxDepth,
+		sem:      make(chan struct{}, concurrency),
+	}
+
+	root = "/" + strings.Trim(root, "/")
+	listing := w.fetch(root)
+	<-listing.done
+
+	if listing.err != nil {
+		err := fn(root, nil, listing.err)
+		if err == SkipDir {
+			return nil
+		}
+		return err
+	}
+
+	err := w.walk(root, listing.meta, 0, listing)
+	if err == SkipDir {
+		return nil
+	}
+	return err
+}
+
+// The state of a walk
+type walker struct {
+	fs       *FileService
+	ctx      context.Context
+	fn       WalkFunc
+	maxDepth int
+
+	// Bounds the concurrent fetches
+	sem chan struct{}
+}
+
+// The fetch of a directory listing, done is closed when finished
+type dirListing struct {
+	meta *Meta
+	err  error
+	done chan struct{}
+}
+
+// Fetches the directory listing in the background
+func (w *walker) fetch(dirPath string) *dirListing {
+	listing := &dirListing{done: make(chan struct{})}
+
+	go func() {
+		defer close(listing.done)
+
+		select {
+		case w.sem <- struct{}{}:
+			defer func() { <-w.sem }()
+		case <-w.ctx.Done():
+			listing.err = w.ctx.Err()
+			return
+		}
+
+		listing.meta, listing.err = w.fs.getMetaAllChildren(w.ctx, dirPath)
+	}()
+
+	return listing
+}
+
+func (w *walker) walk(filePath string, meta *Meta, depth int, listing *dirListing) error {
+	if err := w.fn(filePath, meta, nil); err != nil {
+		if err == SkipDir && meta.IsDir() {
+			return nil
+		}
+		return err
+	}
+
+	if !meta.IsDir() || (w.maxDepth > 0 && depth >= w.maxDepth) {
+		return nil
+	}
+
+	// Not prefetched
+	if listing == nil {
+		listing = w.fetch(filePath)
+	}
+
+	select {
+	case <-listing.done:
+	case <-w.ctx.Done():
+		return w.ctx.Err()
+	}
+
+	if listing.err != nil {
+		if err := w.fn(filePath, meta, listing.err); err != nil && err != SkipDir {
+			return err
+		}
+		return nil
+	}
+
+	children := listing.meta.Children
+	sort.SliceStable(children, func(i, j int) bool {
+		return children[i].Name < children[j].Name
+	})
+
+	// The subdirectories that will be walked are prefetched with a window of
+	// the concurrency, so the pending listings of a directory are bounded
+	subDirs := []int{}
+	if w.maxDepth == 0 || depth+1 < w.maxDepth {
+		for i := range children {
+			if children[i].IsDir() {
+				subDirs = append(subDirs, i)
+			}
+		}
+	}
+
+	subListings := make([]*dirListing, len(children))
+	prefetch := func(k int) {
+		if k < len(subDirs) {
+			i := subDirs[k]
+			subListings[i] = w.fetch(path.Join(filePath, children[i].Name))
+		}
+	}
+
+	window := cap(w.sem)
+	for k := 0; k < window; k++ {
+		prefetch(k)
+	}
+
+	walkedDirs := 0
+	for i := range children {
+		child := &children[i]
+
+		// Slide the window
+		if subListings[i] != nil {
+			prefetch(walkedDirs + window)
+			walkedDirs++
+		}
+
+		err := w.walk(path.Join(filePath, child.Name), child, depth+1, subListings[i])
+		if err == SkipDir {
+			// Skipped from a file, skip the rest of the directory
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns the metadata of a directory with all the children, Copy returns
+// the children paginated so we iterate over all the pages
+func (fs *FileService) getMetaAllChildren(ctx context.Context, dirPath string) (*Meta, error) {
+	children := []Meta{}
+
+	it := fs.ListChildren(ctx, dirPath, nil)
+	for it.Next() {
+		children = append(children, *it.Meta())
+	}
+
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	meta := it.Parent()
+	meta.Children = children
+	return meta, nil
+}
+
+// Returns the metadata of a path with a page of the children, the form has
+// the pagination params (list_index, list_count...)
+func (fs *FileService) getMetaPage(ctx context.Context, dirPath string, form url.Values) (*Meta, error) {
+	dirPath = strings.Trim(dirPath, "/")
+
+	// The root of the user files
+	endpoint := firstLevelSuffix
+	if dirPath != "" {
+		endpoint = fmt.Sprintf(getMetaSuffix, dirPath)
+	}
+
+	meta := new(Meta)
+	_, err := fs.client.DoRequestDecodingContext(ctx, "GET", endpoint, form, meta)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return meta, nil
+}
@@ -0,0 +1,232 @@
+package copy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Setups a remote tree:
+//
+//	/
+//	├── a/
+//	│   ├── a1.txt
+//	│   ├── a2.txt
+//	│   └── c/
+//	│       └── c1.txt
+//	└── b.txt
+//
+// The listing of a is paginated in 2 pages and the root is listed unsorted
+func setupWalkTree(t *testing.T) {
+	setupFileService(t)
+
+	mux.HandleFunc("/"+firstLevelSuffix,
+		func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, "GET")
+			fmt.Fprint(w, `{
+                "path":"/", "name":"Copy", "type":"copy", "children_count":2,
+                "children":[
+                    {"path":"/b.txt", "name":"b.txt", "type":"file", "size":3, "list_index":0},
+                    {"path":"/a", "name":"a", "type":"dir", "list_index":1}
+                ]}`)
+		},
+	)
+
+	mux.HandleFunc("/"+fmt.Sprintf(getMetaSuffix, "a"),
+		func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, "GET")
+			if r.URL.Query().Get("list_index") == "2" {
+				fmt.Fprint(w, `{
+                    "path":"/a", "name":"a", "type":"dir", "children_count":3,
+                    "children":[
+                        {"path":"/a/c", "name":"c", "type":"dir", "list_index":2}
+                    ]}`)
+				return
+			}
+			fmt.Fprint(w, `{
+                "path":"/a", "name":"a", "type":"dir", "children_count":3,
+                "children":[
+                    {"path":"/a/a1.txt", "name":"a1.txt", "type":"file", "list_index":0},
+                    {"path":"/a/a2.txt", "name":"a2.txt", "type":"file", "list_index":1}
+                ]}`)
+		},
+	)
+
+	mux.HandleFunc("/"+fmt.Sprintf(getMetaSuffix, "a/c"),
+		func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, "GET")
+			fmt.Fprint(w, `{
+                "path":"/a/c", "name":"c", "type":"dir", "children_count":1,
+                "children":[
+                    {"path":"/a/c/c1.txt", "name":"c1.txt", "type":"file", "list_index":0}
+                ]}`)
+		},
+	)
+}
+
+func TestWalk(t *testing.T) {
+	setupWalkTree(t)
+	defer tearDownFileService()
+
+	visited := []string{}
+	err := fileService.Walk(context.Background(), "/", func(path string, meta *Meta, err error) error {
+		if err != nil {
+			t.Errorf("Shouldn't be an error: %v", err)
+		}
+		visited = append(visited, path)
+		return nil
+	})
+
+	if err != nil {
+		t.Errorf("Shouldn't be an error: %v", err)
+	}
+
+	want := []string{"/", "/a", "/a/a1.txt", "/a/a2.txt", "/a/c", "/a/c/c1.txt", "/b.txt"}
+	if !reflect.DeepEqual(visited, want) {
+		t.Errorf("Wrong walk: %v", visited)
+	}
+}
+
+func TestWalkSkipDirAndDepth(t *testing.T) {
+	setupWalkTree(t)
+	defer tearDownFileService()
+
+	// Skip a
+	visited := []string{}
+	fileService.Walk(context.Background(), "", func(path string, meta *Meta, err error) error {
+		visited = append(visited, path)
+		if path == "/a" {
+			return SkipDir
+		}
+		return nil
+	})
+
+	if want := []string{"/", "/a", "/b.txt"}; !reflect.DeepEqual(visited, want) {
+		t.Errorf("Wrong walk skipping a: %v", visited)
+	}
+
+	// Skip the rest of a from a file
+	visited = []string{}
+	err := fileService.Walk(context.Background(), "/", func(path string, meta *Meta, err error) error {
+		visited = append(visited, path)
+		if path == "/a/a1.txt" {
+			return SkipDir
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Errorf("Shouldn't be an error: %v", err)
+	}
+
+	if want := []string{"/", "/a", "/a/a1.txt", "/b.txt"}; !reflect.DeepEqual(visited, want) {
+		t.Errorf("Wrong walk skipping from a1.txt: %v", visited)
+	}
+
+	// Only the first level of a
+	visited = []string{}
+	opts := &WalkOptions{MaxDepth: 1, Concurrency: 1}
+	fileService.WalkWithOptions(context.Background(), "a", opts, func(path string, meta *Meta, err error) error {
+		visited = append(visited, path)
+		return nil
+	})
+
+	if want := []string{"/a", "/a/a1.txt", "/a/a2.txt", "/a/c"}; !reflect.DeepEqual(visited, want) {
+		t.Errorf("Wrong walk with depth: %v", visited)
+	}
+}
+
+func TestWalkErrors(t *testing.T) {
+	setupWalkTree(t)
+	defer tearDownFileService()
+
+	// Stop the walk
+	stop := errors.New("stop")
+	visited := 0
+	err := fileService.Walk(context.Background(), "/", func(path string, meta *Meta, err error) error {
+		visited++
+		if path == "/a/a1.txt" {
+			return stop
+		}
+		return nil
+	})
+
+	if err != stop || visited != 3 {
+		t.Errorf("Walk should be stopped: %v, %d", err, visited)
+	}
+
+	// Wrong directories are reported
+	var walkErr error
+	fileService.Walk(context.Background(), "/doesntexist", func(path string, meta *Meta, err error) error {
+		walkErr = err
+		return nil
+	})
+
+	if !IsNotFound(walkErr) {
+		t.Errorf("Should be a not found error: %v", walkErr)
+	}
+
+	// Cancelled walk
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = fileService.Walk(ctx, "/", func(path string, meta *Meta, err error) error {
+		return err
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Should be a cancelled error: %v", err)
+	}
+}
+
+func TestWalkPrefetchWindow(t *testing.T) {
+	setupFileService(t)
+	defer tearDownFileService()
+
+	// A wide directory of 10 empty directories
+	mux.HandleFunc("/"+fmt.Sprintf(getMetaSuffix, "wide"),
+		func(w http.ResponseWriter, r *http.Request) {
+			children := []string{}
+			for i := 0; i < 10; i++ {
+				children = append(children, fmt.Sprintf(`{"path":"/wide/d%d", "name":"d%d", "type":"dir", "list_index":%d}`, i, i, i))
+			}
+			fmt.Fprintf(w, `{"path":"/wide", "name":"wide", "type":"dir", "children_count":10, "children":[%s]}`,
+				strings.Join(children, ","))
+		},
+	)
+
+	var listed int32
+	mux.HandleFunc("/"+fmt.Sprintf(getMetaSuffix, "wide")+"/",
+		func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&listed, 1)
+			fmt.Fprintf(w, `{"path":"%s", "type":"dir", "children_count":0, "children":[]}`,
+				strings.TrimPrefix(r.URL.Path, "/meta/copy"))
+		},
+	)
+
+	// Only 2 directories are fetched ahead of the walk
+	var visitedDirs int32
+	opts := &WalkOptions{Concurrency: 2}
+	err := fileService.WalkWithOptions(context.Background(), "wide", opts, func(path string, meta *Meta, err error) error {
+		if path == "/wide" {
+			return nil
+		}
+
+		time.Sleep(5 * time.Millisecond)
+		visitedDirs++
+		if n := atomic.LoadInt32(&listed); n > visitedDirs+2 {
+			t.Errorf("Too many directories fetched at %s: %d", path, n)
+		}
+		return nil
+	})
+
+	if err != nil || visitedDirs != 10 || atomic.LoadInt32(&listed) != 10 {
+		t.Errorf("Wrong walk: %v, %d visited, %d listed", err, visitedDirs, atomic.LoadInt32(&listed))
+	}
+}
@@ -0,0 +1,279 @@
+package copy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// The type of change of a watch event
+type EventType int
+
+const (
+	Created EventType = iota
+	Modified
+	Deleted
+	Renamed
+)
+
+func (t EventType) String() string {
+	switch t {
+	case Created:
+		return "created"
+	case Modified:
+		return "modified"
+	case Deleted:
+		return "deleted"
+	case Renamed:
+		return "renamed"
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+// Event is a change found by Watch. If Err is set the poll failed and the
+// rest of the fields are empty, the watch continues
+type Event struct {
+	Type EventType
+
+	// The path of the file, the new path if renamed
+	Path string
+
+	// The previous path of the renamed files
+	OldPath string
+
+	// The metadata of the file (the last one known if deleted)
+	Meta *Meta
+
+	Err error
+}
+
+// Watch options
+type WatchOptions struct {
+	// Time between polls
+	Interval time.Duration
+
+	// When nothing changes the interval is doubled until MaxInterval, the
+	// first change resets it. By default 8 times the interval
+	MaxInterval time.Duration
+
+	// Maximum depth to watch, the root is depth 0, 0 means no limit
+	MaxDepth int
+
+	// Size of the events channel
+	BufferSize int
+}
+
+const (
+	defaultWatchInterval    = 30 * time.Second
+	defaultWatchIdleBackoff = 8
+)
+
+// Watches the remote path for changes polling its tree every interval (the
+// interval grows while nothing changes). The changes are found comparing the
+// revisions and modified times of the files with the previous poll, the first
+// poll only takes the snapshot. The channel is closed when the context is
+// done
+func (fs *FileService) Watch(ctx context.Context, path string, interval time.Duration) <-chan Event {
+	return fs.WatchWithOptions(ctx, path, &WatchOptions{Interval: interval})
+}
+
+// Like Watch but with options
+func (fs *FileService) WatchWithOptions(ctx context.Context, path string, opts *WatchOptions) <-chan Event {
+	o := WatchOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Interval <= 0 {
+		o.Interval = defaultWatchInterval
+	}
+	if o.MaxInterval < o.Interval {
+		o.MaxInterval = o.Interval * defaultWatchIdleBackoff
+	}
+
+	events := make(chan Event, o.BufferSize)
+
+	go func() {
+		defer close(events)
+
+		send := func(e Event) bool {
+			select {
+			case events <- e:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		var previous map[string]*Meta
+		interval := o.Interval
+
+		for {
+			current, err := fs.watchSnapshot(ctx, path, o.MaxDepth)
+
+			switch {
+			case ctx.Err() != nil:
+				return
+			case err != nil:
+				if !send(Event{Err: err}) {
+					return
+				}
+				interval = nextWatchInterval(interval, o.MaxInterval)
+			case previous == nil:
+				previous = current
+			default:
+				changes := diffSnapshots(previous, current)
+				previous = current
+
+				for _, e := range changes {
+					if !send(e) {
+						return
+					}
+				}
+
+				if len(changes) > 0 {
+					interval = o.Interval
+				} else {
+					interval = nextWatchInterval(interval, o.MaxInterval)
+				}
+			}
+
+			timer := time.NewTimer(interval)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+		}
+	}()
+
+	return events
+}
+
+func nextWatchInterval(interval, max time.Duration) time.Duration {
+	interval *= 2
+	if interval > max {
+		interval = max
+	}
+	return interval
+}
+
+// Returns the metadata of the files of the tree by path (the root not
+// included), if the root doesn't exist the snapshot is empty
+func (fs *FileService) watchSnapshot(ctx context.Context, root string, maxDepth int) (map[string]*Meta, error) {
+	snapshot := map[string]*Meta{}
+	rootPath := ""
+
+	err := fs.WalkWithOptions(ctx, root, &WalkOptions{MaxDepth: maxDepth}, func(path string, meta *Meta, err error) error {
+		if err != nil {
+			if meta == nil && IsNotFound(err) {
+				return SkipDir
+			}
+			return err
+		}
+
+		if rootPath == "" {
+			rootPath = path
+			return nil
+		}
+
+		m := *meta
+		m.Children = nil
+		snapshot[path] = &m
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// Returns the changes between the snapshots: renames, creations,
+// modifications and deletions, each ordered by path
+func diffSnapshots(previous, current map[string]*Meta) []Event {
+	created := []string{}
+	deleted := []string{}
+	events := []Event{}
+
+	for _, p := range sortedMetaPaths(current) {
+		old, ok := previous[p]
+		switch {
+		case !ok:
+			created = append(created, p)
+		case metaChanged(old, current[p]):
+			events = append(events, Event{Type: Modified, Path: p, Meta: current[p]})
+		}
+	}
+
+	for _, p := range sortedMetaPaths(previous) {
+		if _, ok := current[p]; !ok {
+			deleted = append(deleted, p)
+		}
+	}
+
+	// A deleted file that appears in other path was renamed
+	renames := []Event{}
+	renamedFrom := map[string]bool{}
+	renamedTo := map[string]bool{}
+
+	for _, to := range created {
+		for _, from := range deleted {
+			if !renamedFrom[from] && sameFile(previous[from], current[to]) {
+				renames = append(renames, Event{Type: Renamed, Path: to, OldPath: from, Meta: current[to]})
+				renamedFrom[from] = true
+				renamedTo[to] = true
+				break
+			}
+		}
+	}
+
+	result := renames
+	for _, p := range created {
+		if !renamedTo[p] {
+			result = append(result, Event{Type: Created, Path: p, Meta: current[p]})
+		}
+	}
+	result = append(result, events...)
+	for _, p := range deleted {
+		if !renamedFrom[p] {
+			result = append(result, Event{Type: Deleted, Path: p, Meta: previous[p]})
+		}
+	}
+
+	return result
+}
+
+// Checks if the file changed, the directories only change with their
+// children
+func metaChanged(old, current *Meta) bool {
+	if old.Type != current.Type {
+		return true
+	}
+
+	if current.IsDir() {
+		return false
+	}
+
+	return old.Revision != current.Revision || old.RevisionId != current.RevisionId ||
+		!old.ModifiedTime.Equal(current.ModifiedTime.Time) || old.Size != current.Size
+}
+
+// Checks if the metadata of two paths is from the same file by the type,
+// size, revision and modified time (the id of Copy is the path, it changes
+// with the renames)
+func sameFile(a, b *Meta) bool {
+	return !a.IsDir() && a.Type == b.Type && a.Size == b.Size &&
+		a.ModifiedTime.Equal(b.ModifiedTime.Time) && a.Revision == b.Revision
+}
+
+func sortedMetaPaths(snapshot map[string]*Meta) []string {
+	paths := make([]string, 0, len(snapshot))
+	for p := range snapshot {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,166 @@
+package copy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Setups a remote dir whose children can be changed, returns the function
+// for changing them and the function that returns the number of polls
+func setupWatchDir(t *testing.T) (func(children ...string), func() int) {
+	setupFileService(t)
+
+	var mu sync.Mutex
+	children := []string{}
+	status := http.StatusOK
+	polls := 0
+
+	mux.HandleFunc("/"+fmt.Sprintf(getMetaSuffix, "watched"),
+		func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			polls++
+			if status != http.StatusOK {
+				w.WriteHeader(status)
+				return
+			}
+
+			fmt.Fprintf(w, `{"path":"/watched", "name":"watched", "type":"dir", "children_count":%d, "children":[%s]}`,
+				len(children), strings.Join(children, ","))
+		},
+	)
+
+	setChildren := func(newChildren ...string) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		// A status code instead of children
+		if len(newChildren) == 1 && !strings.HasPrefix(newChildren[0], "{") {
+			fmt.Sscan(newChildren[0], &status)
+			return
+		}
+		status = http.StatusOK
+		children = newChildren
+	}
+
+	getPolls := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return polls
+	}
+
+	return setChildren, getPolls
+}
+
+// Returns the next event or fails
+func nextEvent(t *testing.T, events <-chan Event) Event {
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(2 * time.Second):
+		t.Fatal("No event received")
+	}
+	return Event{}
+}
+
+func TestWatch(t *testing.T) {
+	setChildren, polls := setupWatchDir(t)
+	defer tearDownFileService()
+
+	a := `{"id":"/copy/watched/a.txt", "path":"/watched/a.txt", "name":"a.txt", "type":"file", "size":1, "revision":1, "modified_time":1386150047, "list_index":0}`
+	aModified := `{"id":"/copy/watched/a.txt", "path":"/watched/a.txt", "name":"a.txt", "type":"file", "size":2, "revision":2, "modified_time":1386150100, "list_index":0}`
+	aRenamed := `{"id":"/copy/watched/b.txt", "path":"/watched/b.txt", "name":"b.txt", "type":"file", "size":2, "revision":2, "modified_time":1386150100, "list_index":0}`
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := fileService.WatchWithOptions(ctx, "watched", &WatchOptions{
+		Interval:    5 * time.Millisecond,
+		MaxInterval: 20 * time.Millisecond,
+	})
+
+	// Let the first snapshot be taken
+	for polls() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	steps := []struct {
+		children []string
+		expected Event
+	}{
+		{[]string{a}, Event{Type: Created, Path: "/watched/a.txt"}},
+		{[]string{aModified}, Event{Type: Modified, Path: "/watched/a.txt"}},
+		{[]string{aRenamed}, Event{Type: Renamed, Path: "/watched/b.txt", OldPath: "/watched/a.txt"}},
+		{[]string{}, Event{Type: Deleted, Path: "/watched/b.txt"}},
+	}
+
+	for _, step := range steps {
+		setChildren(step.children...)
+
+		e := nextEvent(t, events)
+		if e.Err != nil || e.Meta == nil {
+			t.Fatalf("Wrong event: %+v", e)
+		}
+
+		e.Meta = nil
+		if !reflect.DeepEqual(e, step.expected) {
+			t.Errorf("Wrong event: %+v, expected: %+v", e, step.expected)
+		}
+	}
+
+	// The errors are sent and the watch continues
+	setChildren("500")
+	if e := nextEvent(t, events); e.Err == nil {
+		t.Errorf("Should be an error event: %+v", e)
+	}
+
+	setChildren(a)
+	for e := nextEvent(t, events); e.Err != nil; e = nextEvent(t, events) {
+	}
+
+	// The channel is closed with the context
+	cancel()
+	for range events {
+	}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	previous := map[string]*Meta{
+		"/dir":       {Path: "/dir", Type: "dir", Revision: 1},
+		"/old.txt":   {Path: "/old.txt", Type: "file", Size: 10, ModifiedTime: NewTimestamp(100), Revision: 1},
+		"/same.txt":  {Path: "/same.txt", Type: "file", Size: 1, ModifiedTime: NewTimestamp(100), Revision: 1},
+		"/gone.txt":  {Path: "/gone.txt", Type: "file", Size: 5, ModifiedTime: NewTimestamp(100), Revision: 1},
+		"/touch.txt": {Path: "/touch.txt", Type: "file", Size: 1, ModifiedTime: NewTimestamp(100), Revision: 1},
+	}
+
+	current := map[string]*Meta{
+		"/dir":       {Path: "/dir", Type: "dir", Revision: 2},
+		"/new.txt":   {Path: "/new.txt", Type: "file", Size: 10, ModifiedTime: NewTimestamp(100), Revision: 1},
+		"/same.txt":  {Path: "/same.txt", Type: "file", Size: 1, ModifiedTime: NewTimestamp(100), Revision: 1},
+		"/other.txt": {Path: "/other.txt", Type: "file", Size: 7, ModifiedTime: NewTimestamp(200), Revision: 1},
+		"/touch.txt": {Path: "/touch.txt", Type: "file", Size: 1, ModifiedTime: NewTimestamp(200), Revision: 1},
+	}
+
+	summary := []string{}
+	for _, e := range diffSnapshots(previous, current) {
+		summary = append(summary, strings.TrimSpace(fmt.Sprintf("%s %s %s", e.Type, e.Path, e.OldPath)))
+	}
+
+	expected := []string{
+		"renamed /new.txt /old.txt",
+		"created /other.txt",
+		"modified /touch.txt",
+		"deleted /gone.txt",
+	}
+
+	if !reflect.DeepEqual(summary, expected) {
+		t.Errorf("Wrong changes: %v", summary)
+	}
+}
@@ -0,0 +1,338 @@
+// Package copyfs exposes a Copy folder as a read only io/fs file system, so
+// it can be used with fs.WalkDir, template.ParseFS, http.FS...
+//
+//	fsys := copyfs.New(files, "website")
+//	http.Handle("/", http.FileServer(http.FS(fsys)))
+//
+// Every operation asks Copy, there is no cache.
+package copyfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/slok/go-copy/copy"
+)
+
+// FS is a Copy folder as an io/fs file system
+type FS struct {
+	files *copy.FileService
+	root  string
+	ctx   context.Context
+}
+
+var (
+	_ fs.FS         = (*FS)(nil)
+	_ fs.ReadDirFS  = (*FS)(nil)
+	_ fs.StatFS     = (*FS)(nil)
+	_ fs.ReadFileFS = (*FS)(nil)
+)
+
+// Creates a file system of the remote root folder ("" is all the Copy files)
+func New(files *copy.FileService, root string) *FS {
+	return &FS{
+		files: files,
+		root:  strings.Trim(root, "/"),
+		ctx:   context.Background(),
+	}
+}
+
+// Returns a copy of the file system that makes the requests with the context
+func (f *FS) WithContext(ctx context.Context) *FS {
+	return &FS{
+		files: f.files,
+		root:  f.root,
+		ctx:   ctx,
+	}
+}
+
+// Returns the remote path of a name of the file system
+func (f *FS) remotePath(name string) string {
+	if name == "." {
+		return f.root
+	}
+	return strings.Trim(path.Join(f.root, name), "/")
+}
+
+// Returns the error of an operation with the io/fs errors
+func pathError(op, name string, err error) error {
+	if copy.IsNotFound(err) {
+		err = fs.ErrNotExist
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+func (f *FS) Open(name string) (fs.File, error) {
+	info, err := f.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return &dir{fsys: f, name: name, info: info}, nil
+	}
+
+	return &file{fsys: f, name: name, info: info}, nil
+}
+
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	return f.stat("stat", name)
+}
+
+func (f *FS) stat(op, name string) (*fileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	remote := f.remotePath(name)
+
+	// The root of the Copy files has no metadata
+	if remote == "" {
+		return &fileInfo{name: ".", meta: &copy.Meta{Path: "/", Type: "root"}}, nil
+	}
+
+	meta, err := f.files.GetMetaContext(f.ctx, remote)
+	if err != nil {
+		return nil, pathError(op, name, err)
+	}
+	meta.Children = nil
+
+	return &fileInfo{name: path.Base(name), meta: meta}, nil
+}
+
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	entries := []fs.DirEntry{}
+
+	it := f.files.ListChildren(f.ctx, f.remotePath(name), nil)
+	for it.Next() {
+		meta := *it.Meta()
+		entries = append(entries, fs.FileInfoToDirEntry(&fileInfo{name: meta.Name, meta: &meta}))
+	}
+
+	if err := it.Err(); err != nil {
+		return nil, pathError("readdir", name, err)
+	}
+
+	if parent := it.Parent(); parent != nil && !parent.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+func (f *FS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrInvalid}
+	}
+
+	r, err := f.files.GetFileContext(f.ctx, f.remotePath(name))
+	if err != nil {
+		return nil, pathError("readfile", name, err)
+	}
+	defer r.Close()
+
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, pathError("readfile", name, err)
+	}
+
+	return content, nil
+}
+
+// The metadata of a file as fs.FileInfo, the files are read only
+type fileInfo struct {
+	name string
+	meta *copy.Meta
+}
+
+func (i *fileInfo) Name() string {
+	return i.name
+}
+
+func (i *fileInfo) Size() int64 {
+	return int64(i.meta.Size)
+}
+
+func (i *fileInfo) Mode() fs.FileMode {
+	if i.IsDir() {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+func (i *fileInfo) ModTime() time.Time {
+	return i.meta.ModifiedTime.Time
+}
+
+func (i *fileInfo) IsDir() bool {
+	return i.meta.IsDir()
+}
+
+// Returns the *copy.Meta
+func (i *fileInfo) Sys() interface{} {
+	return i.meta
+}
+
+// An opened file, the content is requested on the first read. Seeking
+// requests the content from the new offset
+type file struct {
+	fsys *FS
+	name string
+	info *fileInfo
+
+	body   io.ReadCloser
+	offset int64
+	closed bool
+}
+
+func (f *file) Stat() (fs.FileInfo, error) {
+	if f.closed {
+		return nil, &fs.PathError{Op: "stat", Path: f.name, Err: fs.ErrClosed}
+	}
+	return f.info, nil
+}
+
+func (f *file) Read(b []byte) (int, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrClosed}
+	}
+
+	if f.offset >= f.info.Size() {
+		return 0, io.EOF
+	}
+
+	if f.body == nil {
+		var err error
+		remote := f.fsys.remotePath(f.name)
+
+		if f.offset == 0 {
+			f.body, err = f.fsys.files.GetFileContext(f.fsys.ctx, remote)
+		} else {
+			f.body, err = f.fsys.files.GetFileRange(f.fsys.ctx, remote, f.offset, -1)
+		}
+
+		if err != nil {
+			return 0, pathError("read", f.name, err)
+		}
+	}
+
+	n, err := f.body.Read(b)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrClosed}
+	}
+
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.Size()
+	}
+
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+
+	// The content will be requested again from the new offset
+	if offset != f.offset && f.body != nil {
+		f.body.Close()
+		f.body = nil
+	}
+
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *file) Close() error {
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+
+	f.closed = true
+	if f.body != nil {
+		return f.body.Close()
+	}
+	return nil
+}
+
+// An opened directory, the entries are requested on the first ReadDir
+type dir struct {
+	fsys *FS
+	name string
+	info *fileInfo
+
+	entries []fs.DirEntry
+	listed  bool
+	closed  bool
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) {
+	if d.closed {
+		return nil, &fs.PathError{Op: "stat", Path: d.name, Err: fs.ErrClosed}
+	}
+	return d.info, nil
+}
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if d.closed {
+		return nil, &fs.PathError{Op: "readdir", Path: d.name, Err: fs.ErrClosed}
+	}
+
+	if !d.listed {
+		entries, err := d.fsys.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries = entries
+		d.listed = true
+	}
+
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+func (d *dir) Close() error {
+	if d.closed {
+		return &fs.PathError{Op: "close", Path: d.name, Err: fs.ErrClosed}
+	}
+	d.closed = true
+	return nil
+}
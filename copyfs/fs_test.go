@@ -0,0 +1,236 @@
+package copyfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/slok/go-copy/copy"
+)
+
+var modTime = time.Date(2014, 5, 1, 10, 0, 0, 0, time.UTC)
+
+// The remote files, the directories end with /
+var remoteFiles = map[string]string{
+	"site/":                 "",
+	"site/index.html":       "<h1>{{.}}</h1>",
+	"site/css/":             "",
+	"site/css/style.css":    "body { color: red; }",
+	"site/empty/":           "",
+	"site/docs/":            "",
+	"site/docs/a.txt":       "a",
+	"site/docs/big.bin":     strings.Repeat("0123456789", 1000),
+	"site/docs/nested/":     "",
+	"site/docs/nested/b.md": "# b",
+	"other.txt":             "other",
+}
+
+// Starts a read only Copy API with the remote files
+func setupFS(t *testing.T) (*copy.FileService, func()) {
+	metaOf := func(p string) (copy.Meta, bool) {
+		if content, ok := remoteFiles[p]; ok {
+			return copy.Meta{Path: "/" + p, Name: path.Base(p), Type: "file", Size: copy.Size(len(content)), ModifiedTime: copy.NewTimestamp(modTime.Unix())}, true
+		}
+		if _, ok := remoteFiles[p+"/"]; ok {
+			return copy.Meta{Path: "/" + p, Name: path.Base(p), Type: "dir", ModifiedTime: copy.NewTimestamp(modTime.Unix())}, true
+		}
+		return copy.Meta{}, false
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/meta/copy/", func(w http.ResponseWriter, r *http.Request) {
+		p := strings.Trim(strings.TrimPrefix(r.URL.Path, "/meta/copy"), "/")
+		meta, ok := metaOf(p)
+		if p == "" {
+			meta, ok = copy.Meta{Path: "/", Type: "root"}, true
+		}
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if meta.IsDir() && r.URL.Query().Get("list_index") == "" {
+			names := []string{}
+			for f := range remoteFiles {
+				f = strings.TrimSuffix(f, "/")
+				if path.Dir(f) == p || (p == "" && !strings.Contains(f, "/")) {
+					names = append(names, f)
+				}
+			}
+			sort.Strings(names)
+
+			for i, name := range names {
+				child, _ := metaOf(name)
+				child.ListIndex = i
+				meta.Children = append(meta.Children, child)
+			}
+			meta.ChildrenCount = len(names)
+		}
+
+		json.NewEncoder(w).Encode(meta)
+	})
+
+	mux.HandleFunc("/files/", func(w http.ResponseWriter, r *http.Request) {
+		p := strings.Trim(strings.TrimPrefix(r.URL.Path, "/files"), "/")
+		content, ok := remoteFiles[p]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		http.ServeContent(w, r, path.Base(p), modTime, strings.NewReader(content))
+	})
+
+	server := httptest.NewServer(mux)
+
+	client, err := copy.New(
+		copy.WithBaseURL(server.URL),
+		copy.WithCredentials("a", "b", "c", "d"),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	return copy.NewFileService(client), server.Close
+}
+
+func TestFS(t *testing.T) {
+	files, teardown := setupFS(t)
+	defer teardown()
+
+	fsys := New(files, "/site/")
+
+	if err := fstest.TestFS(fsys, "index.html", "css/style.css", "docs/a.txt", "docs/big.bin", "docs/nested/b.md", "empty"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// All the Copy files
+	if err := fstest.TestFS(New(files, ""), "other.txt", "site/index.html"); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestFSInfo(t *testing.T) {
+	files, teardown := setupFS(t)
+	defer teardown()
+
+	fsys := New(files, "site")
+
+	info, err := fs.Stat(fsys, "docs/big.bin")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if info.Name() != "big.bin" || info.Size() != 10000 || info.Mode() != 0444 ||
+		!info.ModTime().Equal(modTime) || info.IsDir() {
+		t.Errorf("Wrong info: %v %v %v %v", info.Name(), info.Size(), info.Mode(), info.ModTime())
+	}
+
+	if meta, ok := info.Sys().(*copy.Meta); !ok || meta.Path != "/site/docs/big.bin" {
+		t.Errorf("Sys should be the meta: %+v", info.Sys())
+	}
+
+	info, _ = fs.Stat(fsys, "docs")
+	if !info.IsDir() || info.Mode() != fs.ModeDir|0555 {
+		t.Errorf("Wrong dir info: %v", info.Mode())
+	}
+
+	if _, err := fs.Stat(fsys, "nope.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Should be a not exist error: %v", err)
+	}
+
+	if _, err := fsys.Open("../other.txt"); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("Should be an invalid path error: %v", err)
+	}
+
+	if _, err := fs.ReadDir(fsys, "index.html"); err == nil {
+		t.Errorf("Files can't be read as dirs")
+	}
+}
+
+func TestFSSeek(t *testing.T) {
+	files, teardown := setupFS(t)
+	defer teardown()
+
+	f, err := New(files, "site").Open("docs/big.bin")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer f.Close()
+
+	seeker := f.(io.ReadSeeker)
+	content := []byte(remoteFiles["site/docs/big.bin"])
+
+	seeker.Seek(-15, io.SeekEnd)
+	b, _ := ioutil.ReadAll(seeker)
+	if !bytes.Equal(b, content[len(content)-15:]) {
+		t.Errorf("Wrong content from the end: %q", b)
+	}
+
+	seeker.Seek(3, io.SeekStart)
+	b = make([]byte, 4)
+	io.ReadFull(seeker, b)
+	if string(b) != "3456" {
+		t.Errorf("Wrong content from offset: %q", b)
+	}
+}
+
+func TestFSAdapters(t *testing.T) {
+	files, teardown := setupFS(t)
+	defer teardown()
+
+	fsys := New(files, "site")
+
+	// Templates
+	tmpl, err := template.ParseFS(fsys, "*.html")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	out := new(bytes.Buffer)
+	tmpl.Execute(out, "hello")
+	if out.String() != "<h1>hello</h1>" {
+		t.Errorf("Wrong template: %s", out)
+	}
+
+	// HTTP with ranges
+	server := httptest.NewServer(http.FileServer(http.FS(fsys)))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL+"/docs/big.bin", nil)
+	req.Header.Set("Range", "bytes=10-19")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer resp.Body.Close()
+
+	b, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusPartialContent || string(b) != "0123456789" {
+		t.Errorf("Wrong range response: %d %q", resp.StatusCode, b)
+	}
+
+	// Walk
+	walked := []string{}
+	fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		walked = append(walked, p)
+		return err
+	})
+
+	expected := ". css css/style.css docs docs/a.txt docs/big.bin docs/nested docs/nested/b.md empty index.html"
+	if strings.Join(walked, " ") != expected {
+		t.Errorf("Wrong walk: %v", walked)
+	}
+}
@@ -0,0 +1,245 @@
+package copyfuse
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slok/go-copy/copy"
+)
+
+// Returned by the caches when the path doesn't exist
+var errNotFound = errors.New("not found")
+
+// Caches the directory listings of the remote tree for a time, the paths
+// are relative to the root with slashes ("" is the root)
+type metaCache struct {
+	files *copy.FileService
+	root  string
+	ttl   time.Duration
+
+	mu   sync.Mutex
+	dirs map[string]*dirListing
+}
+
+// The children of a directory by name
+type dirListing struct {
+	children map[string]copy.Meta
+	fetched  time.Time
+}
+
+func newMetaCache(files *copy.FileService, root string, ttl time.Duration) *metaCache {
+	return &metaCache{
+		files: files,
+		root:  strings.Trim(root, "/"),
+		ttl:   ttl,
+		dirs:  map[string]*dirListing{},
+	}
+}
+
+// Returns the remote path of a path of the tree
+func (c *metaCache) remotePath(p string) string {
+	return strings.Trim(path.Join(c.root, p), "/")
+}
+
+// Returns the children of the directory, from the cache if they are fresh
+func (c *metaCache) list(ctx context.Context, dir string) (map[string]copy.Meta, error) {
+	c.mu.Lock()
+	listing, ok := c.dirs[dir]
+	c.mu.Unlock()
+
+	if ok && time.Since(listing.fetched) < c.ttl {
+		return listing.children, nil
+	}
+
+	children := map[string]copy.Meta{}
+	it := c.files.ListChildren(ctx, c.remotePath(dir), nil)
+	for it.Next() {
+		meta := *it.Meta()
+		children[meta.Name] = meta
+	}
+
+	if err := it.Err(); err != nil {
+		if copy.IsNotFound(err) {
+			return nil, errNotFound
+		}
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.dirs[dir] = &dirListing{children: children, fetched: time.Now()}
+	c.mu.Unlock()
+
+	return children, nil
+}
+
+// Returns the sorted names of the children of the directory
+func (c *metaCache) names(ctx context.Context, dir string) ([]string, map[string]copy.Meta, error) {
+	children, err := c.list(ctx, dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names := make([]string, 0, len(children))
+	for name := range children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, children, nil
+}
+
+// Returns the metadata of the path from the listing of its parent
+func (c *metaCache) lookup(ctx context.Context, p string) (*copy.Meta, error) {
+	if p == "" {
+		return &copy.Meta{Path: "/" + c.root, Type: "dir"}, nil
+	}
+
+	children, err := c.list(ctx, parentDir(p))
+	if err != nil {
+		return nil, err
+	}
+
+	meta, ok := children[path.Base(p)]
+	if !ok {
+		return nil, errNotFound
+	}
+
+	return &meta, nil
+}
+
+// Returns the parent directory of a path of the tree
+func parentDir(p string) string {
+	dir := path.Dir(p)
+	if dir == "." || dir == "/" {
+		return ""
+	}
+	return dir
+}
+
+// Forgets the listing of the directory, the next lookup will ask Copy
+func (c *metaCache) invalidate(dir string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.dirs, dir)
+}
+
+// Forgets the directory and all its subdirectories
+func (c *metaCache) invalidateTree(dir string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for d := range c.dirs {
+		if d == dir || dir == "" || strings.HasPrefix(d, dir+"/") {
+			delete(c.dirs, d)
+		}
+	}
+}
+
+// Caches the content of the remote files in a local directory, a file is
+// downloaded again when its revision changes
+type contentCache struct {
+	files *copy.FileService
+	dir   string
+
+	// Serializes the downloads of the same file
+	mu        sync.Mutex
+	inFlight  map[string]*sync.Mutex
+	ownsCache bool
+}
+
+// Creates the cache in the directory, if it is empty a temporal directory
+// is used (removed by close)
+func newContentCache(files *copy.FileService, dir string) (*contentCache, error) {
+	owns := false
+	if dir == "" {
+		var err error
+		dir, err = ioutil.TempDir("", "go-copy-fuse")
+		if err != nil {
+			return nil, err
+		}
+		owns = true
+	} else if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &contentCache{
+		files:     files,
+		dir:       dir,
+		inFlight:  map[string]*sync.Mutex{},
+		ownsCache: owns,
+	}, nil
+}
+
+// Returns the local path of a revision of a remote file
+func (c *contentCache) cachePath(remotePath string, meta *copy.Meta) string {
+	sum := sha1.Sum([]byte(remotePath))
+	return filepath.Join(c.dir, fmt.Sprintf("%x-%d-%d-%d", sum, meta.Revision, meta.RevisionId, meta.ModifiedTime.Seconds()))
+}
+
+// Opens the cached content of the remote file, downloading it if it isn't
+// cached
+func (c *contentCache) open(ctx context.Context, remotePath string, meta *copy.Meta) (*os.File, error) {
+	local := c.cachePath(remotePath, meta)
+
+	c.mu.Lock()
+	lock, ok := c.inFlight[local]
+	if !ok {
+		lock = &sync.Mutex{}
+		c.inFlight[local] = lock
+	}
+	c.mu.Unlock()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if _, err := os.Stat(local); os.IsNotExist(err) {
+		if err := c.files.DownloadToFile(ctx, remotePath, local, nil); err != nil {
+			if copy.IsNotFound(err) {
+				return nil, errNotFound
+			}
+			return nil, err
+		}
+	}
+
+	return os.Open(local)
+}
+
+// Creates a temporal file for writing the content of a file before
+// uploading it, with the cached content if copyFrom is not nil
+func (c *contentCache) tempFile(copyFrom *os.File) (*os.File, error) {
+	tmp, err := ioutil.TempFile(c.dir, "write-")
+	if err != nil {
+		return nil, err
+	}
+
+	if copyFrom != nil {
+		if _, err := copyFrom.Seek(0, 0); err == nil {
+			_, err = tmp.ReadFrom(copyFrom)
+		}
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return nil, err
+		}
+	}
+
+	return tmp, nil
+}
+
+// Removes the cache if it was created by the cache
+func (c *contentCache) close() error {
+	if c.ownsCache {
+		return os.RemoveAll(c.dir)
+	}
+	return nil
+}
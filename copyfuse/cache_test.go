@@ -0,0 +1,169 @@
+package copyfuse
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/slok/go-copy/internal/copytest"
+)
+
+func TestMetaCacheLookup(t *testing.T) {
+	fake := copytest.NewServer(t)
+	defer fake.Close()
+
+	fake.Put("photos/a.jpg", []byte("aaa"), time.Now())
+	fake.Put("photos/b/c.jpg", []byte("cc"), time.Now())
+
+	cache := newMetaCache(fake.FS, "photos", time.Minute)
+	ctx := context.Background()
+
+	meta, err := cache.lookup(ctx, "a.jpg")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if meta.Size != 3 || meta.IsDir() {
+		t.Errorf("Wrong meta of a.jpg: %+v", meta)
+	}
+
+	meta, err = cache.lookup(ctx, "b/c.jpg")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if meta.Size != 2 {
+		t.Errorf("Wrong meta of b/c.jpg: %+v", meta)
+	}
+
+	if _, err := cache.lookup(ctx, "missing"); err != errNotFound {
+		t.Errorf("Missing file should be not found: %v", err)
+	}
+	if _, err := cache.lookup(ctx, "missing/file"); err != errNotFound {
+		t.Errorf("File of a missing directory should be not found: %v", err)
+	}
+
+	names, _, err := cache.names(ctx, "")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(names) != 2 || names[0] != "a.jpg" || names[1] != "b" {
+		t.Errorf("Wrong names: %v", names)
+	}
+}
+
+func TestMetaCacheTTL(t *testing.T) {
+	fake := copytest.NewServer(t)
+	defer fake.Close()
+
+	fake.Put("a.txt", []byte("a"), time.Now())
+
+	cache := newMetaCache(fake.FS, "", time.Minute)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := cache.lookup(ctx, "a.txt"); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	if requests, _ := fake.Counters(); requests != 1 {
+		t.Errorf("Listing should be cached, got %d requests", requests)
+	}
+
+	// New files are not seen until the listing is invalidated
+	fake.Put("b.txt", []byte("b"), time.Now())
+	if _, err := cache.lookup(ctx, "b.txt"); err != errNotFound {
+		t.Errorf("Cached listing should not have b.txt: %v", err)
+	}
+
+	cache.invalidate("")
+	if _, err := cache.lookup(ctx, "b.txt"); err != nil {
+		t.Errorf("Invalidated listing should have b.txt: %v", err)
+	}
+
+	// Expired listings are fetched again
+	cache.ttl = 0
+	cache.lookup(ctx, "a.txt")
+	if requests, _ := fake.Counters(); requests != 3 {
+		t.Errorf("Expired listing should be fetched, got %d requests", requests)
+	}
+}
+
+func TestContentCache(t *testing.T) {
+	fake := copytest.NewServer(t)
+	defer fake.Close()
+
+	fake.Put("a.txt", []byte("first"), time.Now())
+
+	dir, err := ioutil.TempDir("", "copyfuse-test")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	cache, err := newContentCache(fake.FS, dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	meta := newMetaCache(fake.FS, "", time.Minute)
+	ctx := context.Background()
+
+	read := func() string {
+		m, err := meta.lookup(ctx, "a.txt")
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		f, err := cache.open(ctx, "a.txt", m)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		defer f.Close()
+		data, _ := ioutil.ReadAll(f)
+		return string(data)
+	}
+
+	if got := read(); got != "first" {
+		t.Errorf("Wrong content: %q", got)
+	}
+	if got := read(); got != "first" {
+		t.Errorf("Wrong cached content: %q", got)
+	}
+	if _, downloads := fake.Counters(); downloads != 1 {
+		t.Errorf("Content should be cached, got %d downloads", downloads)
+	}
+
+	// A new revision is downloaded again
+	fake.Put("a.txt", []byte("second"), time.Now())
+	meta.invalidate("")
+	if got := read(); got != "second" {
+		t.Errorf("Wrong content of the new revision: %q", got)
+	}
+	if _, downloads := fake.Counters(); downloads != 2 {
+		t.Errorf("New revision should be downloaded, got %d downloads", downloads)
+	}
+
+	// The cache directory is not ours
+	if err := cache.close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("Cache directory should be kept: %v", err)
+	}
+}
+
+func TestContentCacheTemporalDir(t *testing.T) {
+	fake := copytest.NewServer(t)
+	defer fake.Close()
+
+	cache, err := newContentCache(fake.FS, "")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := cache.close(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := os.Stat(cache.dir); !os.IsNotExist(err) {
+		t.Errorf("Temporal cache directory should be removed: %v", err)
+	}
+}
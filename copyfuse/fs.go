@@ -0,0 +1,619 @@
+//go:build linux
+// +build linux
+
+// Package copyfuse mounts a Copy account (or a directory of it) as a local
+// filesystem with FUSE.
+//
+// The directory listings are cached for a time (Options.MetaTTL) and the
+// content of the files is downloaded to a local cache when they are opened,
+// so the reads are local. In read-write mode the writes go to a local copy
+// of the file that is uploaded when the file is flushed or closed.
+package copyfuse
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"sync"
+	"syscall"
+	"time"
+
+	"bazil.org/fuse"
+	fusefs "bazil.org/fuse/fs"
+	"github.com/slok/go-copy/copy"
+)
+
+// Mount options
+type Options struct {
+	// Mount read-only, the writes fail with EROFS
+	ReadOnly bool
+
+	// Directory of the content cache, it is kept between mounts. By default
+	// a temporal directory removed on unmount
+	CacheDir string
+
+	// Time the directory listings are cached, 30 seconds by default
+	MetaTTL time.Duration
+
+	// Owner of the files, the current user by default
+	Uid uint32
+	Gid uint32
+}
+
+const defaultMetaTTL = 30 * time.Second
+
+// FS is the FUSE filesystem of a Copy directory
+type FS struct {
+	files    *copy.FileService
+	meta     *metaCache
+	content  *contentCache
+	readOnly bool
+	uid, gid uint32
+
+	// The files open for writing by path, their local copy is the content
+	// until they are uploaded
+	mu      sync.Mutex
+	writing map[string]*writeHandle
+}
+
+// Creates the filesystem of the remote root directory ("" or "/" for the
+// whole account)
+func NewFS(files *copy.FileService, root string, opts *Options) (*FS, error) {
+	o := Options{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.MetaTTL <= 0 {
+		o.MetaTTL = defaultMetaTTL
+	}
+	if o.Uid == 0 && o.Gid == 0 {
+		o.Uid = uint32(os.Getuid())
+		o.Gid = uint32(os.Getgid())
+	}
+
+	content, err := newContentCache(files, o.CacheDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FS{
+		files:    files,
+		meta:     newMetaCache(files, root, o.MetaTTL),
+		content:  content,
+		readOnly: o.ReadOnly,
+		uid:      o.Uid,
+		gid:      o.Gid,
+		writing:  map[string]*writeHandle{},
+	}, nil
+}
+
+// Mounts the remote root directory in the mountpoint and serves it until it
+// is unmounted or the context is done
+func Mount(ctx context.Context, files *copy.FileService, root, mountpoint string, opts *Options) error {
+	filesys, err := NewFS(files, root, opts)
+	if err != nil {
+		return err
+	}
+	defer filesys.Close()
+
+	mountOpts := []fuse.MountOption{
+		fuse.FSName("copy"),
+		fuse.Subtype("copyfs"),
+	}
+	if filesys.readOnly {
+		mountOpts = append(mountOpts, fuse.ReadOnly())
+	}
+
+	conn, err := fuse.Mount(mountpoint, mountOpts...)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- fusefs.Serve(conn, filesys)
+	}()
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+		if err := fuse.Unmount(mountpoint); err != nil {
+			return err
+		}
+		<-served
+		return ctx.Err()
+	}
+}
+
+// Removes the content cache if it is temporal
+func (f *FS) Close() error {
+	return f.content.close()
+}
+
+func (f *FS) Root() (fusefs.Node, error) {
+	return &dir{fsys: f, path: ""}, nil
+}
+
+// Returns the open write handle of the path
+func (f *FS) openForWriting(p string) *writeHandle {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.writing[p]
+}
+
+func (f *FS) attr(a *fuse.Attr, meta *copy.Meta) {
+	a.Uid = f.uid
+	a.Gid = f.gid
+	a.Mtime = meta.ModifiedTime.Time
+	a.Ctime = a.Mtime
+	a.Atime = a.Mtime
+
+	if meta.IsDir() {
+		a.Mode = os.ModeDir | 0755
+		a.Nlink = 2
+	} else {
+		a.Mode = 0644
+		a.Nlink = 1
+		a.Size = uint64(meta.Size)
+		a.Blocks = (a.Size + 511) / 512
+	}
+
+	if f.readOnly {
+		a.Mode &^= 0222
+	}
+}
+
+// Maps the errors to the FUSE errors
+func toErrno(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case err == errNotFound || copy.IsNotFound(err):
+		return fuse.ENOENT
+	case err == context.Canceled || err == context.DeadlineExceeded:
+		return fuse.EINTR
+	}
+	return err
+}
+
+var errReadOnly = fuse.Errno(syscall.EROFS)
+
+var (
+	_ fusefs.FS                 = (*FS)(nil)
+	_ fusefs.NodeStringLookuper = (*dir)(nil)
+	_ fusefs.HandleReadDirAller = (*dir)(nil)
+	_ fusefs.NodeMkdirer        = (*dir)(nil)
+	_ fusefs.NodeCreater        = (*dir)(nil)
+	_ fusefs.NodeRemover        = (*dir)(nil)
+	_ fusefs.NodeRenamer        = (*dir)(nil)
+	_ fusefs.NodeOpener         = (*file)(nil)
+	_ fusefs.NodeSetattrer      = (*file)(nil)
+	_ fusefs.NodeFsyncer        = (*file)(nil)
+	_ fusefs.HandleReader       = (*readHandle)(nil)
+	_ fusefs.HandleReleaser     = (*readHandle)(nil)
+	_ fusefs.HandleWriter       = (*writeHandle)(nil)
+	_ fusefs.HandleFlusher      = (*writeHandle)(nil)
+	_ fusefs.HandleReleaser     = (*writeHandle)(nil)
+)
+
+// A directory of the tree
+type dir struct {
+	fsys *FS
+	path string
+}
+
+func (d *dir) Attr(ctx context.Context, a *fuse.Attr) error {
+	meta, err := d.fsys.meta.lookup(ctx, d.path)
+	if err != nil {
+		return toErrno(err)
+	}
+	d.fsys.attr(a, meta)
+	return nil
+}
+
+func (d *dir) child(name string) string {
+	return path.Join(d.path, name)
+}
+
+func (d *dir) Lookup(ctx context.Context, name string) (fusefs.Node, error) {
+	p := d.child(name)
+
+	// The new files only exist locally until they are uploaded
+	if d.fsys.openForWriting(p) != nil {
+		return &file{fsys: d.fsys, path: p}, nil
+	}
+
+	meta, err := d.fsys.meta.lookup(ctx, p)
+	if err != nil {
+		return nil, toErrno(err)
+	}
+
+	if meta.IsDir() {
+		return &dir{fsys: d.fsys, path: p}, nil
+	}
+	return &file{fsys: d.fsys, path: p}, nil
+}
+
+func (d *dir) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	names, children, err := d.fsys.meta.names(ctx, d.path)
+	if err != nil {
+		return nil, toErrno(err)
+	}
+
+	dirents := make([]fuse.Dirent, 0, len(names))
+	for _, name := range names {
+		dirent := fuse.Dirent{Name: name, Type: fuse.DT_File}
+		if meta := children[name]; meta.IsDir() {
+			dirent.Type = fuse.DT_Dir
+		}
+		dirents = append(dirents, dirent)
+	}
+
+	return dirents, nil
+}
+
+func (d *dir) Mkdir(ctx context.Context, req *fuse.MkdirRequest) (fusefs.Node, error) {
+	if d.fsys.readOnly {
+		return nil, errReadOnly
+	}
+
+	p := d.child(req.Name)
+	if err := d.fsys.files.CreateDirectoryContext(ctx, d.fsys.meta.remotePath(p), false); err != nil {
+		return nil, toErrno(err)
+	}
+	d.fsys.meta.invalidate(d.path)
+
+	return &dir{fsys: d.fsys, path: p}, nil
+}
+
+func (d *dir) Create(ctx context.Context, req *fuse.CreateRequest, resp *fuse.CreateResponse) (fusefs.Node, fusefs.Handle, error) {
+	if d.fsys.readOnly {
+		return nil, nil, errReadOnly
+	}
+
+	f := &file{fsys: d.fsys, path: d.child(req.Name)}
+	h, err := f.openWrite(ctx, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Created empty in Copy on the first flush
+	h.dirty = true
+	return f, h, nil
+}
+
+func (d *dir) Remove(ctx context.Context, req *fuse.RemoveRequest) error {
+	if d.fsys.readOnly {
+		return errReadOnly
+	}
+
+	p := d.child(req.Name)
+	if req.Dir {
+		children, err := d.fsys.meta.list(ctx, p)
+		if err != nil {
+			return toErrno(err)
+		}
+		if len(children) > 0 {
+			return fuse.Errno(syscall.ENOTEMPTY)
+		}
+	}
+
+	if err := d.fsys.files.DeleteFileContext(ctx, d.fsys.meta.remotePath(p)); err != nil {
+		return toErrno(err)
+	}
+
+	d.fsys.meta.invalidate(d.path)
+	if req.Dir {
+		d.fsys.meta.invalidateTree(p)
+	}
+	return nil
+}
+
+func (d *dir) Rename(ctx context.Context, req *fuse.RenameRequest, newDir fusefs.Node) error {
+	if d.fsys.readOnly {
+		return errReadOnly
+	}
+
+	target, ok := newDir.(*dir)
+	if !ok {
+		return fuse.EIO
+	}
+
+	oldPath := d.child(req.OldName)
+	newPath := target.child(req.NewName)
+	remote := d.fsys.meta.remotePath(oldPath)
+
+	var err error
+	if target.path == d.path {
+		err = d.fsys.files.RenameFileContext(ctx, remote, req.NewName, true)
+	} else {
+		err = d.fsys.files.MoveFileContext(ctx, remote, d.fsys.meta.remotePath(newPath), true)
+	}
+	if err != nil {
+		return toErrno(err)
+	}
+
+	d.fsys.meta.invalidate(d.path)
+	d.fsys.meta.invalidate(target.path)
+	d.fsys.meta.invalidateTree(oldPath)
+	return nil
+}
+
+// A file of the tree
+type file struct {
+	fsys *FS
+	path string
+}
+
+func (f *file) Attr(ctx context.Context, a *fuse.Attr) error {
+	if h := f.fsys.openForWriting(f.path); h != nil {
+		return h.attr(a)
+	}
+
+	meta, err := f.fsys.meta.lookup(ctx, f.path)
+	if err != nil {
+		return toErrno(err)
+	}
+	f.fsys.attr(a, meta)
+	return nil
+}
+
+func (f *file) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenResponse) (fusefs.Handle, error) {
+	if req.Flags.IsReadOnly() {
+		if h := f.fsys.openForWriting(f.path); h != nil {
+			return h.acquire(), nil
+		}
+
+		meta, err := f.fsys.meta.lookup(ctx, f.path)
+		if err != nil {
+			return nil, toErrno(err)
+		}
+
+		local, err := f.fsys.content.open(ctx, f.fsys.meta.remotePath(f.path), meta)
+		if err != nil {
+			return nil, toErrno(err)
+		}
+
+		return &readHandle{local: local}, nil
+	}
+
+	if f.fsys.readOnly {
+		return nil, errReadOnly
+	}
+
+	h, err := f.openWrite(ctx, req.Flags&fuse.OpenTruncate != 0)
+	if err != nil {
+		return nil, err
+	}
+	if req.Flags&fuse.OpenTruncate != 0 {
+		h.dirty = true
+	}
+	return h, nil
+}
+
+// Returns the write handle of the file, creating the local copy of the
+// content if it isn't open yet
+func (f *file) openWrite(ctx context.Context, truncate bool) (*writeHandle, error) {
+	if h := f.fsys.openForWriting(f.path); h != nil {
+		h.acquire()
+		if truncate {
+			h.mu.Lock()
+			err := h.local.Truncate(0)
+			h.mu.Unlock()
+			if err != nil {
+				h.release()
+				return nil, err
+			}
+		}
+		return h, nil
+	}
+
+	meta, err := f.fsys.meta.lookup(ctx, f.path)
+	if err != nil && err != errNotFound {
+		return nil, toErrno(err)
+	}
+	exists := err == nil
+
+	var cached *os.File
+	if exists && !truncate {
+		cached, err = f.fsys.content.open(ctx, f.fsys.meta.remotePath(f.path), meta)
+		if err != nil {
+			return nil, toErrno(err)
+		}
+		defer cached.Close()
+	}
+
+	local, err := f.fsys.content.tempFile(cached)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &writeHandle{fsys: f.fsys, path: f.path, local: local, exists: exists, refs: 1}
+
+	f.fsys.mu.Lock()
+	defer f.fsys.mu.Unlock()
+
+	// Other open could have won
+	if other, ok := f.fsys.writing[f.path]; ok {
+		local.Close()
+		os.Remove(local.Name())
+		return other.acquire(), nil
+	}
+
+	f.fsys.writing[f.path] = h
+	return h, nil
+}
+
+func (f *file) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
+	if req.Valid.Size() {
+		if f.fsys.readOnly {
+			return errReadOnly
+		}
+
+		h, err := f.openWrite(ctx, req.Size == 0)
+		if err != nil {
+			return err
+		}
+		defer h.release()
+
+		h.mu.Lock()
+		err = h.local.Truncate(int64(req.Size))
+		h.dirty = true
+		h.mu.Unlock()
+
+		if err != nil {
+			return err
+		}
+
+		if err := h.upload(ctx); err != nil {
+			return err
+		}
+	}
+
+	return f.Attr(ctx, &resp.Attr)
+}
+
+func (f *file) Fsync(ctx context.Context, req *fuse.FsyncRequest) error {
+	if h := f.fsys.openForWriting(f.path); h != nil {
+		return h.upload(ctx)
+	}
+	return nil
+}
+
+// Reads the cached content of a file
+type readHandle struct {
+	local *os.File
+}
+
+func (h *readHandle) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+	return readAt(h.local, req, resp)
+}
+
+func (h *readHandle) Release(ctx context.Context, req *fuse.ReleaseRequest) error {
+	return h.local.Close()
+}
+
+func readAt(local *os.File, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+	buf := make([]byte, req.Size)
+	n, err := local.ReadAt(buf, req.Offset)
+	if n == 0 && err != nil && err != io.EOF {
+		return err
+	}
+	resp.Data = buf[:n]
+	return nil
+}
+
+// A file open for writing, shared by all the opens of the file. The content
+// is uploaded on flush (if it changed) and the local copy is removed when
+// the last open is released
+type writeHandle struct {
+	fsys *FS
+	path string
+
+	mu     sync.Mutex
+	local  *os.File
+	exists bool
+	dirty  bool
+	refs   int
+}
+
+func (h *writeHandle) acquire() *writeHandle {
+	h.mu.Lock()
+	h.refs++
+	h.mu.Unlock()
+	return h
+}
+
+// Drops an open, the last one closes the local copy
+func (h *writeHandle) release() {
+	h.fsys.mu.Lock()
+	defer h.fsys.mu.Unlock()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.refs--
+	if h.refs > 0 {
+		return
+	}
+
+	delete(h.fsys.writing, h.path)
+	h.local.Close()
+	os.Remove(h.local.Name())
+}
+
+func (h *writeHandle) attr(a *fuse.Attr) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	info, err := h.local.Stat()
+	if err != nil {
+		return err
+	}
+
+	h.fsys.attr(a, &copy.Meta{Type: "file", Size: copy.Size(info.Size()), ModifiedTime: copy.NewTimestamp(info.ModTime().Unix())})
+	return nil
+}
+
+func (h *writeHandle) Read(ctx context.Context, req *fuse.ReadRequest, resp *fuse.ReadResponse) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return readAt(h.local, req, resp)
+}
+
+func (h *writeHandle) Write(ctx context.Context, req *fuse.WriteRequest, resp *fuse.WriteResponse) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n, err := h.local.WriteAt(req.Data, req.Offset)
+	resp.Size = n
+	if n > 0 {
+		h.dirty = true
+	}
+	return err
+}
+
+func (h *writeHandle) Flush(ctx context.Context, req *fuse.FlushRequest) error {
+	return h.upload(ctx)
+}
+
+func (h *writeHandle) Release(ctx context.Context, req *fuse.ReleaseRequest) error {
+	err := h.upload(ctx)
+	h.release()
+	return err
+}
+
+// Uploads the local copy if it changed, creating the file if it doesn't
+// exist in Copy
+func (h *writeHandle) upload(ctx context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.dirty {
+		return nil
+	}
+
+	if err := h.local.Sync(); err != nil {
+		return err
+	}
+
+	remote := h.fsys.meta.remotePath(h.path)
+
+	var err error
+	if h.exists {
+		err = h.fsys.files.UpdateFileContext(ctx, h.local.Name(), remote)
+	} else {
+		err = h.fsys.files.UploadFileContext(ctx, h.local.Name(), remote, true)
+	}
+	if err != nil {
+		return toErrno(err)
+	}
+
+	h.exists = true
+	h.dirty = false
+	h.fsys.meta.invalidate(parentDir(h.path))
+	return nil
+}
@@ -0,0 +1,300 @@
+//go:build linux
+// +build linux
+
+package copyfuse
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"bazil.org/fuse"
+	fusefs "bazil.org/fuse/fs"
+
+	"github.com/slok/go-copy/internal/copytest"
+)
+
+// The tests call the nodes directly, they don't need FUSE in the system
+func newTestFS(t *testing.T, fake *copytest.Server, readOnly bool) *dir {
+	filesys, err := NewFS(fake.FS, "", &Options{ReadOnly: readOnly})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	root, err := filesys.Root()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return root.(*dir)
+}
+
+func lookupPath(t *testing.T, root *dir, names ...string) fusefs.Node {
+	var node fusefs.Node = root
+	for _, name := range names {
+		var err error
+		node, err = node.(*dir).Lookup(context.Background(), name)
+		if err != nil {
+			t.Fatalf("Could not lookup %s: %v", name, err)
+		}
+	}
+	return node
+}
+
+func readAll(t *testing.T, h fusefs.Handle) string {
+	resp := &fuse.ReadResponse{}
+	err := h.(fusefs.HandleReader).Read(context.Background(), &fuse.ReadRequest{Size: 1024}, resp)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return string(resp.Data)
+}
+
+func TestFSReadDirAndAttr(t *testing.T) {
+	fake := copytest.NewServer(t)
+	defer fake.Close()
+
+	fake.Put("docs/a.txt", []byte("hello"), time.Now())
+	fake.Put("docs/sub", nil, time.Now())
+
+	root := newTestFS(t, fake, false)
+	defer root.fsys.Close()
+	ctx := context.Background()
+
+	docs := lookupPath(t, root, "docs").(*dir)
+	dirents, err := docs.ReadDirAll(ctx)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	want := []fuse.Dirent{
+		{Name: "a.txt", Type: fuse.DT_File},
+		{Name: "sub", Type: fuse.DT_Dir},
+	}
+	if len(dirents) != len(want) || dirents[0] != want[0] || dirents[1] != want[1] {
+		t.Errorf("Wrong dirents: %+v", dirents)
+	}
+
+	var attr fuse.Attr
+	if err := lookupPath(t, root, "docs", "a.txt").Attr(ctx, &attr); err != nil {
+		t.Fatal(err.Error())
+	}
+	if attr.Size != 5 || attr.Mode != 0644 {
+		t.Errorf("Wrong attributes: %+v", attr)
+	}
+
+	if err := lookupPath(t, root, "docs", "sub").Attr(ctx, &attr); err != nil {
+		t.Fatal(err.Error())
+	}
+	if attr.Mode != os.ModeDir|0755 {
+		t.Errorf("Wrong directory mode: %v", attr.Mode)
+	}
+
+	if _, err := docs.Lookup(ctx, "missing"); err != fuse.ENOENT {
+		t.Errorf("Missing file should be ENOENT: %v", err)
+	}
+}
+
+func TestFSRead(t *testing.T) {
+	fake := copytest.NewServer(t)
+	defer fake.Close()
+
+	fake.Put("a.txt", []byte("hello world"), time.Now())
+
+	root := newTestFS(t, fake, true)
+	defer root.fsys.Close()
+	ctx := context.Background()
+
+	f := lookupPath(t, root, "a.txt").(*file)
+	h, err := f.Open(ctx, &fuse.OpenRequest{Flags: fuse.OpenReadOnly}, &fuse.OpenResponse{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	resp := &fuse.ReadResponse{}
+	if err := h.(fusefs.HandleReader).Read(ctx, &fuse.ReadRequest{Offset: 6, Size: 100}, resp); err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(resp.Data) != "world" {
+		t.Errorf("Wrong read: %q", resp.Data)
+	}
+	h.(fusefs.HandleReleaser).Release(ctx, &fuse.ReleaseRequest{})
+
+	// Opened again from the cache
+	h, err = f.Open(ctx, &fuse.OpenRequest{Flags: fuse.OpenReadOnly}, &fuse.OpenResponse{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if got := readAll(t, h); got != "hello world" {
+		t.Errorf("Wrong read: %q", got)
+	}
+	if _, downloads := fake.Counters(); downloads != 1 {
+		t.Errorf("Content should be cached, got %d downloads", downloads)
+	}
+}
+
+func TestFSReadOnly(t *testing.T) {
+	fake := copytest.NewServer(t)
+	defer fake.Close()
+
+	fake.Put("a.txt", []byte("hello"), time.Now())
+
+	root := newTestFS(t, fake, true)
+	defer root.fsys.Close()
+	ctx := context.Background()
+	erofs := fuse.Errno(syscall.EROFS)
+
+	var attr fuse.Attr
+	lookupPath(t, root, "a.txt").Attr(ctx, &attr)
+	if attr.Mode != 0444 {
+		t.Errorf("Wrong read-only mode: %v", attr.Mode)
+	}
+
+	f := lookupPath(t, root, "a.txt").(*file)
+	if _, err := f.Open(ctx, &fuse.OpenRequest{Flags: fuse.OpenWriteOnly}, &fuse.OpenResponse{}); err != erofs {
+		t.Errorf("Open for writing should fail with EROFS: %v", err)
+	}
+	if _, _, err := root.Create(ctx, &fuse.CreateRequest{Name: "b.txt"}, &fuse.CreateResponse{}); err != erofs {
+		t.Errorf("Create should fail with EROFS: %v", err)
+	}
+	if _, err := root.Mkdir(ctx, &fuse.MkdirRequest{Name: "dir"}); err != erofs {
+		t.Errorf("Mkdir should fail with EROFS: %v", err)
+	}
+	if err := root.Remove(ctx, &fuse.RemoveRequest{Name: "a.txt"}); err != erofs {
+		t.Errorf("Remove should fail with EROFS: %v", err)
+	}
+	if err := root.Rename(ctx, &fuse.RenameRequest{OldName: "a.txt", NewName: "b.txt"}, root); err != erofs {
+		t.Errorf("Rename should fail with EROFS: %v", err)
+	}
+
+	if !fake.Exists("a.txt") || fake.Exists("b.txt") || fake.Exists("dir") {
+		t.Error("Read-only mount should not change Copy")
+	}
+}
+
+func TestFSCreateAndWrite(t *testing.T) {
+	fake := copytest.NewServer(t)
+	defer fake.Close()
+
+	root := newTestFS(t, fake, false)
+	defer root.fsys.Close()
+	ctx := context.Background()
+
+	node, h, err := root.Create(ctx, &fuse.CreateRequest{Name: "new.txt", Flags: fuse.OpenReadWrite}, &fuse.CreateResponse{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	wresp := &fuse.WriteResponse{}
+	if err := h.(fusefs.HandleWriter).Write(ctx, &fuse.WriteRequest{Data: []byte("hello")}, wresp); err != nil {
+		t.Fatal(err.Error())
+	}
+	if wresp.Size != 5 {
+		t.Errorf("Wrong written size: %d", wresp.Size)
+	}
+
+	// Visible before the upload
+	var attr fuse.Attr
+	if err := lookupPath(t, root, "new.txt").Attr(ctx, &attr); err != nil {
+		t.Fatal(err.Error())
+	}
+	if attr.Size != 5 {
+		t.Errorf("Wrong size of the file being written: %d", attr.Size)
+	}
+	if fake.Exists("new.txt") {
+		t.Error("File should not be uploaded before the flush")
+	}
+
+	if err := h.(fusefs.HandleFlusher).Flush(ctx, &fuse.FlushRequest{}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if got := fake.Content("new.txt"); got != "hello" {
+		t.Errorf("Wrong uploaded content: %q", got)
+	}
+	if err := h.(fusefs.HandleReleaser).Release(ctx, &fuse.ReleaseRequest{}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// Update the existing file appending to it
+	h, err = node.(*file).Open(ctx, &fuse.OpenRequest{Flags: fuse.OpenWriteOnly}, &fuse.OpenResponse{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := h.(fusefs.HandleWriter).Write(ctx, &fuse.WriteRequest{Offset: 5, Data: []byte(" world")}, wresp); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := h.(fusefs.HandleReleaser).Release(ctx, &fuse.ReleaseRequest{}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if got := fake.Content("new.txt"); got != "hello world" {
+		t.Errorf("Wrong updated content: %q", got)
+	}
+
+	// Truncate
+	resp := &fuse.SetattrResponse{}
+	if err := node.(*file).Setattr(ctx, &fuse.SetattrRequest{Valid: fuse.SetattrSize, Size: 4}, resp); err != nil {
+		t.Fatal(err.Error())
+	}
+	if got := fake.Content("new.txt"); got != "hell" {
+		t.Errorf("Wrong truncated content: %q", got)
+	}
+	if resp.Attr.Size != 4 {
+		t.Errorf("Wrong truncated size: %d", resp.Attr.Size)
+	}
+
+	// The write handles are released
+	if h := root.fsys.openForWriting("new.txt"); h != nil {
+		t.Error("Write handle should be released")
+	}
+}
+
+func TestFSMkdirRemoveRename(t *testing.T) {
+	fake := copytest.NewServer(t)
+	defer fake.Close()
+
+	fake.Put("a.txt", []byte("a"), time.Now())
+	fake.Put("full/b.txt", []byte("b"), time.Now())
+
+	root := newTestFS(t, fake, false)
+	defer root.fsys.Close()
+	ctx := context.Background()
+
+	node, err := root.Mkdir(ctx, &fuse.MkdirRequest{Name: "dir"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !fake.Exists("dir") {
+		t.Error("Directory should be created")
+	}
+	newDir := node.(*dir)
+
+	// Same directory is a rename, other directory a move
+	if err := root.Rename(ctx, &fuse.RenameRequest{OldName: "a.txt", NewName: "c.txt"}, root); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := root.Rename(ctx, &fuse.RenameRequest{OldName: "c.txt", NewName: "d.txt"}, newDir); err != nil {
+		t.Fatal(err.Error())
+	}
+	if got := fake.Content("dir/d.txt"); got != "a" || fake.Exists("a.txt") || fake.Exists("c.txt") {
+		t.Errorf("File should be moved to dir/d.txt")
+	}
+	lookupPath(t, root, "dir", "d.txt")
+
+	if err := root.Remove(ctx, &fuse.RemoveRequest{Name: "full", Dir: true}); err != fuse.Errno(syscall.ENOTEMPTY) {
+		t.Errorf("Removing a non empty directory should fail with ENOTEMPTY: %v", err)
+	}
+
+	if err := newDir.Remove(ctx, &fuse.RemoveRequest{Name: "d.txt"}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := root.Remove(ctx, &fuse.RemoveRequest{Name: "dir", Dir: true}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if fake.Exists("dir") {
+		t.Error("Directory should be removed")
+	}
+	if _, err := root.Lookup(ctx, "dir"); err != fuse.ENOENT {
+		t.Errorf("Removed directory should be ENOENT: %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/slok/go-copy/copy"
+	"os"
+	"time"
+)
+
+func main() {
+	// The app tokens, the user will authorize this app
+	appToken := copy.AppToken{
+		Token: os.Getenv("APP_TOKEN"),
+		Key:   os.Getenv("APP_SECRET"),
+	}
+
+	authorizer, err := copy.NewAuthorizer(appToken)
+	if err != nil {
+		fmt.Fprint(os.Stderr, "Could not create the authorizer, review the app params")
+		os.Exit(-1)
+	}
+
+	// Give the user some time to authorize the app
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	accessToken, err := authorizer.AuthorizeWithLoopback(ctx, "", func(authorizeURL string) error {
+		fmt.Printf("Open this URL in your browser and authorize the app:\n\n%s\n\n", authorizeURL)
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not authorize the app: %v", err)
+		os.Exit(-1)
+	}
+
+	fmt.Printf("[default]\napp_token = %s\napp_secret = %s\naccess_token = %s\naccess_secret = %s\n",
+		appToken.Token, appToken.Key, accessToken.Token, accessToken.Key)
+}
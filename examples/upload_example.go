@@ -25,14 +25,14 @@ func main() {
 		os.Exit(-1)
 	}
 
-	// Take all the necessary data
-	appToken := os.Getenv("APP_TOKEN")
-	appSecret := os.Getenv("APP_SECRET")
-	accessToken := os.Getenv("ACCESS_TOKEN")
-	accessSecret := os.Getenv("ACCESS_SECRET")
+	// Load the credentials from the env vars or ~/.copy/credentials
+	credentials := copy.NewChainCredentialsProvider(
+		copy.NewEnvCredentialsProvider(),
+		copy.NewFileCredentialsProvider("", ""),
+	)
 
 	// Create the client
-	client, _ := copy.NewDefaultClient(appToken, appSecret, accessToken, accessSecret)
+	client, _ := copy.New(copy.WithCredentialsProvider(credentials))
 	fs := copy.NewFileService(client)
 	fmt.Println(fs.UploadFile(*filePath, *uploadPath, true))
 	os.Exit(0)
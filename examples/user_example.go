@@ -8,14 +8,14 @@ import (
 )
 
 func main() {
-	//Prepare the neccesary data
-	appToken := os.Getenv("APP_TOKEN")
-	appSecret := os.Getenv("APP_SECRET")
-	accessToken := os.Getenv("ACCESS_TOKEN")
-	accessSecret := os.Getenv("ACCESS_SECRET")
+	// Load the credentials from the env vars or ~/.copy/credentials
+	credentials := copy.NewChainCredentialsProvider(
+		copy.NewEnvCredentialsProvider(),
+		copy.NewFileCredentialsProvider("", ""),
+	)
 
 	// Create the client
-	client, err := copy.NewDefaultClient(appToken, appSecret, accessToken, accessSecret)
+	client, err := copy.New(copy.WithCredentialsProvider(credentials))
 	if err != nil {
 		fmt.Fprint(os.Stderr, "Could not create the client, review the auth params")
 		os.Exit(-1)
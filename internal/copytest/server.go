@@ -0,0 +1,281 @@
+// Package copytest provides an in memory Copy API for the tests of the
+// packages built on top of the copy package.
+package copytest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slok/go-copy/copy"
+)
+
+// Server is a fake Copy API, it only knows about the file system calls (meta,
+// downloads, uploads, updates, deletes, renames and moves). The paths are
+// relative to the root without slashes at the ends
+type Server struct {
+	// File service that uses the server
+	FS *copy.FileService
+
+	mu       sync.Mutex
+	files    map[string]*file
+	revision int
+
+	// "METHOD path" of the changes
+	changes []string
+
+	// Number of listings and downloads served
+	metaRequests int
+	downloads    int
+
+	server *httptest.Server
+}
+
+type file struct {
+	isDir    bool
+	content  []byte
+	modTime  int64
+	revision int
+}
+
+// Starts a fake Copy API, it must be closed
+func NewServer(t testing.TB) *Server {
+	s := &Server{files: map[string]*file{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/meta/copy", s.handleMeta)
+	mux.HandleFunc("/meta/copy/", s.handleMeta)
+	mux.HandleFunc("/files/", s.handleFiles)
+	s.server = httptest.NewServer(mux)
+
+	client, err := copy.New(
+		copy.WithBaseURL(s.server.URL),
+		copy.WithCredentials("a", "b", "c", "d"),
+	)
+	if err != nil {
+		s.server.Close()
+		t.Fatal(err.Error())
+	}
+	s.FS = copy.NewFileService(client)
+
+	return s
+}
+
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Puts a file (or a directory if the content is nil) without registering a
+// change, the parents are created
+func (s *Server) Put(p string, content []byte, modTime time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.putLocked(p, content, modTime.Unix())
+}
+
+func (s *Server) putLocked(p string, content []byte, modTime int64) {
+	p = strings.Trim(p, "/")
+	for dir := path.Dir(p); dir != "." && s.files[dir] == nil; dir = path.Dir(dir) {
+		s.files[dir] = &file{isDir: true, modTime: modTime}
+	}
+
+	s.revision++
+	s.files[p] = &file{
+		isDir:    content == nil,
+		content:  content,
+		modTime:  modTime,
+		revision: s.revision,
+	}
+}
+
+// Deletes a file without registering a change, the children are kept
+func (s *Server) Remove(p string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.files, strings.Trim(p, "/"))
+}
+
+// Returns the content of a file, empty if it doesn't exist
+func (s *Server) Content(p string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, ok := s.files[p]; ok && !f.isDir {
+		return string(f.content)
+	}
+	return ""
+}
+
+func (s *Server) Exists(p string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.files[p]
+	return ok
+}
+
+// Returns the changes made since the last call sorted
+func (s *Server) TakeChanges() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changes := s.changes
+	s.changes = nil
+	sort.Strings(changes)
+	return changes
+}
+
+// Returns the number of directory listings and downloads served
+func (s *Server) Counters() (metaRequests, downloads int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.metaRequests, s.downloads
+}
+
+func (s *Server) meta(p string, f *file) copy.Meta {
+	meta := copy.Meta{
+		Path:         "/" + p,
+		Name:         path.Base(p),
+		Type:         "dir",
+		ModifiedTime: copy.NewTimestamp(f.modTime),
+		Revision:     f.revision,
+		RevisionId:   f.revision,
+	}
+	if !f.isDir {
+		meta.Type = "file"
+		meta.Size = copy.Size(len(f.content))
+	}
+	return meta
+}
+
+func notFound(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte(`{"error":1021,"message":"Cannot find object"}`))
+}
+
+func (s *Server) handleMeta(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := strings.Trim(strings.TrimPrefix(r.URL.Path, "/meta/copy"), "/")
+
+	meta := copy.Meta{Path: "/", Name: "", Type: "root"}
+	if p != "" {
+		f, ok := s.files[p]
+		if !ok {
+			notFound(w)
+			return
+		}
+		meta = s.meta(p, f)
+	}
+
+	if meta.Type != "file" {
+		s.metaRequests++
+
+		names := []string{}
+		for child := range s.files {
+			if path.Dir(child) == p || (p == "" && !strings.Contains(child, "/")) {
+				names = append(names, child)
+			}
+		}
+		sort.Strings(names)
+
+		for i, child := range names {
+			childMeta := s.meta(child, s.files[child])
+			childMeta.ListIndex = i
+			meta.Children = append(meta.Children, childMeta)
+		}
+		meta.ChildrenCount = len(names)
+
+		// Only one page
+		if r.URL.Query().Get("list_index") != "" {
+			meta.Children = nil
+		}
+	}
+
+	json.NewEncoder(w).Encode(meta)
+}
+
+func (s *Server) handleFiles(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := strings.Trim(strings.TrimPrefix(r.URL.Path, "/files"), "/")
+	query := r.URL.Query()
+	now := time.Now().Unix()
+
+	switch {
+	case r.Method == "GET":
+		f, ok := s.files[p]
+		if !ok || f.isDir {
+			notFound(w)
+			return
+		}
+		s.downloads++
+		w.Write(f.content)
+		return
+
+	case r.Method == "DELETE":
+		if _, ok := s.files[p]; !ok {
+			notFound(w)
+			return
+		}
+		for child := range s.files {
+			if child == p || strings.HasPrefix(child, p+"/") {
+				delete(s.files, child)
+			}
+		}
+
+	// Directories don't have body
+	case r.Method == "POST" && !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/"):
+		s.putLocked(p, nil, now)
+
+	case r.Method == "POST":
+		f, header, err := r.FormFile("file")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		content, _ := ioutil.ReadAll(f)
+		p = strings.Trim(path.Join(p, header.Filename), "/")
+		s.putLocked(p, content, now)
+
+	case r.Method == "PUT" && (query.Get("name") != "" || query.Get("path") != ""):
+		if _, ok := s.files[p]; !ok {
+			notFound(w)
+			return
+		}
+
+		newPath := strings.Trim(query.Get("path"), "/")
+		if name := query.Get("name"); name != "" {
+			newPath = strings.Trim(path.Join(path.Dir(p), name), "/")
+		}
+
+		for child, f := range s.files {
+			if child == p || strings.HasPrefix(child, p+"/") {
+				delete(s.files, child)
+				s.files[newPath+strings.TrimPrefix(child, p)] = f
+			}
+		}
+
+	case r.Method == "PUT":
+		if old, ok := s.files[p]; !ok || old.isDir {
+			notFound(w)
+			return
+		}
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		content, _ := ioutil.ReadAll(f)
+		s.putLocked(p, content, now)
+	}
+
+	s.changes = append(s.changes, r.Method+" "+p)
+	w.Write([]byte(`{}`))
+}
@@ -0,0 +1,665 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/slok/go-copy/copy"
+)
+
+// ConflictStrategy is how a conflict (a file changed on both sides since the
+// last sync) is resolved
+type ConflictStrategy int
+
+const (
+	// Keeps both versions, the local file is renamed with the conflict
+	// suffix and uploaded, the remote one is downloaded. If one side deleted
+	// the file the other version is restored
+	KeepBoth ConflictStrategy = iota
+	// The local version wins
+	KeepLocal
+	// The remote version wins
+	KeepRemote
+	// Leaves the conflict for the next sync
+	Skip
+)
+
+func (s ConflictStrategy) String() string {
+	switch s {
+	case KeepBoth:
+		return "keep-both"
+	case KeepLocal:
+		return "keep-local"
+	case KeepRemote:
+		return "keep-remote"
+	case Skip:
+		return "skip"
+	}
+	return fmt.Sprintf("ConflictStrategy(%d)", int(s))
+}
+
+// Conflict is a path that changed on both sides since the last sync
+type Conflict struct {
+	// Path relative to the synced directories, with slashes
+	Path string
+
+	// Deleted on each side
+	LocalDeleted  bool
+	RemoteDeleted bool
+
+	// Directories on each side
+	LocalIsDir  bool
+	RemoteIsDir bool
+
+	// How it was resolved
+	Resolution ConflictStrategy
+}
+
+// Two way sync options
+type BidirectionalOptions struct {
+	ExecuteOptions
+
+	// The file with the state of the last sync, by default .copy-sync.json
+	// in the local directory (never synced)
+	StatePath string
+
+	// How the conflicts are resolved, by default KeepBoth
+	Strategy ConflictStrategy
+
+	// If set it's called for every conflict instead of using the strategy
+	OnConflict func(Conflict) ConflictStrategy
+
+	// Added to the name of the local versions that are kept in the conflicts
+	// ("report.txt" will be "report.conflict.txt"), by default ".conflict"
+	ConflictSuffix string
+
+	// Skips the paths (relative to the synced directories, with slashes) on
+	// both sides. The skipped directories are not walked
+	Exclude func(path string, isDir bool) bool
+}
+
+const defaultConflictSuffix = ".conflict"
+
+// Syncs the changes of both sides since the last sync: the new and changed
+// files are transferred to the other side and the deleted files are deleted
+// on the other side. The files changed on both sides are conflicts resolved
+// with the strategy.
+//
+// Conflicts between a file and a directory can't be kept both, they are
+// skipped with KeepBoth.
+//
+// The remote deletions are checked before planning, if a file of the last
+// sync is missing in the remote listing but it exists the sync fails.
+//
+// After executing the plan the state file is updated (not in dry runs), the
+// failed actions and the skipped conflicts will be tried again in the next
+// sync
+func Bidirectional(ctx context.Context, fs *copy.FileService, localDir, remoteDir string, opts *BidirectionalOptions) (*Report, error) {
+	opts = bidirectionalDefaults(localDir, opts)
+
+	state, err := LoadState(opts.StatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := planBidirectional(ctx, fs, localDir, remoteDir, state, opts)
+	if err != nil {
+		return nil, err
+	}
+	plan := p.plan
+
+	report := plan.Execute(ctx, fs, &opts.ExecuteOptions)
+	if opts.DryRun {
+		return report, nil
+	}
+
+	// The state of the paths that are not synced yet is kept
+	pending := map[string]bool{}
+	for _, res := range report.Results {
+		if res.Err != nil {
+			pending[res.Action.Path] = true
+		}
+	}
+	for _, c := range plan.Conflicts {
+		if c.Resolution == Skip {
+			pending[c.Path] = true
+		}
+	}
+
+	// The new state is built from the trees seen when planning, only the
+	// paths changed by the sync are read again. The changes made meanwhile
+	// to the other paths are synced the next time
+	if err := p.applyResults(ctx, fs, report); err != nil {
+		return report, err
+	}
+
+	newState := NewState()
+	for _, rel := range unionPaths(p.local, p.remote, state) {
+		l, lok := p.local[rel]
+		r, rok := p.remote[rel]
+		old, sok := state.Files[rel]
+
+		switch {
+		case isPending(pending, rel):
+			if sok {
+				newState.Files[rel] = old
+			}
+		case lok && rok && l.isDir == r.isDir:
+			newState.Files[rel] = fileState(l, r)
+		case (lok || rok) && sok:
+			newState.Files[rel] = old
+		}
+	}
+
+	return report, newState.Save(opts.StatePath)
+}
+
+// Returns the actions that Bidirectional would execute
+func PlanBidirectional(ctx context.Context, fs *copy.FileService, localDir, remoteDir string, opts *BidirectionalOptions) (*Plan, error) {
+	opts = bidirectionalDefaults(localDir, opts)
+
+	state, err := LoadState(opts.StatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := planBidirectional(ctx, fs, localDir, remoteDir, state, opts)
+	if err != nil {
+		return nil, err
+	}
+	return p.plan, nil
+}
+
+// Returns a copy of the options with the defaults set
+func bidirectionalDefaults(localDir string, opts *BidirectionalOptions) *BidirectionalOptions {
+	o := BidirectionalOptions{}
+	if opts != nil {
+		o = *opts
+	}
+
+	if o.StatePath == "" {
+		o.StatePath = filepath.Join(localDir, defaultStateFile)
+	}
+
+	if o.ConflictSuffix == "" {
+		o.ConflictSuffix = defaultConflictSuffix
+	}
+
+	// Never sync the state
+	statePath, _ := filepath.Abs(o.StatePath)
+	exclude := o.Exclude
+	o.Exclude = func(p string, isDir bool) bool {
+		if local, err := filepath.Abs(filepath.Join(localDir, filepath.FromSlash(p))); err == nil && local == statePath {
+			return true
+		}
+		return exclude != nil && exclude(p, isDir)
+	}
+
+	return &o
+}
+
+// The planning of a two way sync
+type bidirectionalPlanner struct {
+	plan *Plan
+	opts *BidirectionalOptions
+
+	local  map[string]entry
+	remote map[string]entry
+	state  *State
+
+	localChanged  map[string]bool
+	remoteChanged map[string]bool
+
+	// Deleted directories, their children don't need actions
+	deleted []string
+
+	deletes   []Action
+	renames   []Action
+	mkdirs    []Action
+	transfers []Action
+}
+
+func planBidirectional(ctx context.Context, fs *copy.FileService, localDir, remoteDir string, state *State, opts *BidirectionalOptions) (*bidirectionalPlanner, error) {
+	remoteDir = strings.Trim(remoteDir, "/")
+
+	local, err := localTree(localDir, opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	remote, rootExists, err := remoteTree(ctx, fs, remoteDir, opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	if rootExists {
+		if err := checkRemoteDeleted(ctx, fs, remoteDir, remote, state, opts.Exclude); err != nil {
+			return nil, err
+		}
+	}
+
+	p := &bidirectionalPlanner{
+		plan: &Plan{
+			LocalDir:  localDir,
+			RemoteDir: remoteDir,
+		},
+		opts:          opts,
+		local:         local,
+		remote:        remote,
+		state:         state,
+		localChanged:  map[string]bool{},
+		remoteChanged: map[string]bool{},
+	}
+
+	if !rootExists {
+		p.mkdirs = append(p.mkdirs, p.action(Mkdir, "", 0))
+	}
+
+	paths := unionPaths(local, remote, state)
+	for _, rel := range paths {
+		p.localChanged[rel] = p.isLocalChanged(rel)
+		p.remoteChanged[rel] = p.isRemoteChanged(rel)
+	}
+
+	for _, rel := range paths {
+		if !p.isDeleted(rel) {
+			p.planPath(rel)
+		}
+	}
+
+	p.plan.Actions = append(p.plan.Actions, p.deletes...)
+	p.plan.Actions = append(p.plan.Actions, p.renames...)
+	p.plan.Actions = append(p.plan.Actions, p.mkdirs...)
+	p.plan.Actions = append(p.plan.Actions, p.transfers...)
+
+	return p, nil
+}
+
+// Updates the trees with the actions that succeeded, the changed side of
+// their paths is read again and the children of the deleted directories are
+// removed
+func (p *bidirectionalPlanner) applyResults(ctx context.Context, fs *copy.FileService, report *Report) error {
+	for _, res := range report.Results {
+		a := res.Action
+		if res.Err != nil || a.Path == "" {
+			continue
+		}
+
+		switch a.Type {
+		case Delete:
+			deleteSubtree(p.remote, a.Path)
+		case LocalDelete:
+			deleteSubtree(p.local, a.Path)
+		case LocalRename:
+			delete(p.local, a.OldPath)
+			if err := p.statLocal(a.Path); err != nil {
+				return err
+			}
+		case Download, LocalMkdir:
+			if err := p.statLocal(a.Path); err != nil {
+				return err
+			}
+		case Upload, Update, Mkdir:
+			if err := p.statRemote(ctx, fs, a.Path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Reads the local path again
+func (p *bidirectionalPlanner) statLocal(rel string) error {
+	info, err := os.Lstat(filepath.Join(p.plan.LocalDir, filepath.FromSlash(rel)))
+	switch {
+	case os.IsNotExist(err):
+		delete(p.local, rel)
+	case err != nil:
+		return err
+	default:
+		p.local[rel] = localEntry(info)
+	}
+	return nil
+}
+
+// Reads the remote path again
+func (p *bidirectionalPlanner) statRemote(ctx context.Context, fs *copy.FileService, rel string) error {
+	meta, err := fs.GetMetaContext(ctx, remotePath(p.plan.RemoteDir, rel))
+	switch {
+	case copy.IsNotFound(err):
+		delete(p.remote, rel)
+	case err != nil:
+		return err
+	default:
+		p.remote[rel] = remoteEntry(meta)
+	}
+	return nil
+}
+
+// Checks that the synced paths missing in the remote tree don't exist, an
+// incomplete listing must never be taken as remote deletions (the local
+// files would be deleted). Only the top missing paths are checked
+func checkRemoteDeleted(ctx context.Context, fs *copy.FileService, remoteDir string, remote map[string]entry, state *State, exclude func(string, bool) bool) error {
+	for _, rel := range sortedStatePaths(state) {
+		if _, ok := remote[rel]; ok {
+			continue
+		}
+
+		if exclude != nil && exclude(rel, state.Files[rel].IsDir) {
+			continue
+		}
+
+		if parent := path.Dir(rel); parent != "." {
+			if _, ok := remote[parent]; !ok {
+				continue
+			}
+		}
+
+		_, err := fs.GetMetaContext(ctx, remotePath(remoteDir, rel))
+		if err == nil {
+			return fmt.Errorf("Incomplete remote listing of %s: %s exists", remoteDir, rel)
+		}
+		if !copy.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func sortedStatePaths(state *State) []string {
+	paths := make([]string, 0, len(state.Files))
+	for p := range state.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (p *bidirectionalPlanner) action(t ActionType, rel string, size int64) Action {
+	return Action{
+		Type:       t,
+		Path:       rel,
+		LocalPath:  filepath.Join(p.plan.LocalDir, filepath.FromSlash(rel)),
+		RemotePath: remotePath(p.plan.RemoteDir, rel),
+		Size:       size,
+	}
+}
+
+func (p *bidirectionalPlanner) isLocalChanged(rel string) bool {
+	l, lok := p.local[rel]
+	s, sok := p.state.Files[rel]
+
+	if lok != sok {
+		return true
+	}
+	if !lok {
+		return false
+	}
+
+	return l.isDir != s.IsDir || (!l.isDir && (l.size != s.Size || l.modTime.UnixNano() != s.ModTime))
+}
+
+func (p *bidirectionalPlanner) isRemoteChanged(rel string) bool {
+	r, rok := p.remote[rel]
+	s, sok := p.state.Files[rel]
+
+	if rok != sok {
+		return true
+	}
+	if !rok {
+		return false
+	}
+
+	return r.isDir != s.IsDir || (!r.isDir && (r.revision != s.Revision || r.revisionId != s.RevisionId))
+}
+
+// Checks if a parent directory is deleted by the plan
+func (p *bidirectionalPlanner) isDeleted(rel string) bool {
+	for _, d := range p.deleted {
+		if strings.HasPrefix(rel, d+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks if something changed inside the directory
+func subtreeChanged(changed map[string]bool, dir string) bool {
+	for p, c := range changed {
+		if c && strings.HasPrefix(p, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *bidirectionalPlanner) planPath(rel string) {
+	localChanged := p.localChanged[rel]
+	remoteChanged := p.remoteChanged[rel]
+
+	switch {
+	case !localChanged && !remoteChanged:
+		return
+	case localChanged && !remoteChanged:
+		p.pushLocal(rel, false)
+	case remoteChanged && !localChanged:
+		p.pushRemote(rel, false)
+	default:
+		p.conflict(rel)
+	}
+}
+
+// Makes the remote path like the local one. If the path is a deleted
+// directory with remote changes inside (and not forced) the directory is
+// created again
+func (p *bidirectionalPlanner) pushLocal(rel string, force bool) {
+	l, lok := p.local[rel]
+	r, rok := p.remote[rel]
+
+	if !lok {
+		if !rok {
+			return
+		}
+		if r.isDir && !force && subtreeChanged(p.remoteChanged, rel) {
+			p.mkdirs = append(p.mkdirs, p.action(LocalMkdir, rel, 0))
+			return
+		}
+		p.deletes = append(p.deletes, p.action(Delete, rel, 0))
+		p.deleted = append(p.deleted, rel)
+		return
+	}
+
+	// A file replaces a directory or the opposite
+	if rok && r.isDir != l.isDir {
+		p.deletes = append(p.deletes, p.action(Delete, rel, 0))
+		if r.isDir {
+			p.deleted = append(p.deleted, rel)
+		}
+		rok = false
+	}
+
+	switch {
+	case l.isDir && !rok:
+		p.mkdirs = append(p.mkdirs, p.action(Mkdir, rel, 0))
+	case l.isDir:
+	case rok:
+		p.transfers = append(p.transfers, p.action(Update, rel, l.size))
+	default:
+		p.transfers = append(p.transfers, p.action(Upload, rel, l.size))
+	}
+}
+
+// Makes the local path like the remote one, like pushLocal
+func (p *bidirectionalPlanner) pushRemote(rel string, force bool) {
+	l, lok := p.local[rel]
+	r, rok := p.remote[rel]
+
+	if !rok {
+		if !lok {
+			return
+		}
+		if l.isDir && !force && subtreeChanged(p.localChanged, rel) {
+			p.mkdirs = append(p.mkdirs, p.action(Mkdir, rel, 0))
+			return
+		}
+		p.deletes = append(p.deletes, p.action(LocalDelete, rel, 0))
+		p.deleted = append(p.deleted, rel)
+		return
+	}
+
+	if lok && r.isDir != l.isDir {
+		p.deletes = append(p.deletes, p.action(LocalDelete, rel, 0))
+		if l.isDir {
+			p.deleted = append(p.deleted, rel)
+		}
+		lok = false
+	}
+
+	switch {
+	case r.isDir && !lok:
+		p.mkdirs = append(p.mkdirs, p.action(LocalMkdir, rel, 0))
+	case r.isDir:
+	default:
+		p.transfers = append(p.transfers, p.action(Download, rel, r.size))
+	}
+}
+
+func (p *bidirectionalPlanner) conflict(rel string) {
+	l, lok := p.local[rel]
+	r, rok := p.remote[rel]
+
+	// Deleted on both sides or the same directory created on both
+	if (!lok && !rok) || (lok && rok && l.isDir && r.isDir) {
+		return
+	}
+
+	c := Conflict{
+		Path:          rel,
+		LocalDeleted:  !lok,
+		RemoteDeleted: !rok,
+		LocalIsDir:    lok && l.isDir,
+		RemoteIsDir:   rok && r.isDir,
+	}
+
+	c.Resolution = p.opts.Strategy
+	if p.opts.OnConflict != nil {
+		c.Resolution = p.opts.OnConflict(c)
+	}
+
+	// Directories can't be renamed and uploaded
+	if c.Resolution == KeepBoth && lok && rok && (l.isDir || r.isDir) {
+		c.Resolution = Skip
+	}
+
+	p.plan.Conflicts = append(p.plan.Conflicts, c)
+
+	switch c.Resolution {
+	case KeepLocal:
+		p.pushLocal(rel, true)
+	case KeepRemote:
+		p.pushRemote(rel, true)
+	case KeepBoth:
+		switch {
+		case !lok:
+			p.pushRemote(rel, true)
+		case !rok:
+			p.pushLocal(rel, true)
+		default:
+			conflictRel := p.conflictPath(rel)
+
+			rename := p.action(LocalRename, conflictRel, 0)
+			rename.OldPath = rel
+			p.renames = append(p.renames, rename)
+
+			p.transfers = append(p.transfers,
+				p.action(Upload, conflictRel, l.size),
+				p.action(Download, rel, r.size))
+		}
+	}
+}
+
+// Returns a free path for the local version of a conflict:
+// dir/name.conflict.ext, dir/name.conflict-2.ext...
+func (p *bidirectionalPlanner) conflictPath(rel string) string {
+	dir, name := path.Split(rel)
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	for i := 1; ; i++ {
+		suffix := p.opts.ConflictSuffix
+		if i > 1 {
+			suffix = fmt.Sprintf("%s-%d", suffix, i)
+		}
+
+		candidate := dir + base + suffix + ext
+		_, lok := p.local[candidate]
+		_, rok := p.remote[candidate]
+		if !lok && !rok && !p.isPlanned(candidate) {
+			return candidate
+		}
+	}
+}
+
+// Checks if there is an action for the path
+func (p *bidirectionalPlanner) isPlanned(rel string) bool {
+	for _, actions := range [][]Action{p.renames, p.transfers} {
+		for _, a := range actions {
+			if a.Path == rel {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns the state of a synced path
+func fileState(local, remote entry) FileState {
+	if local.isDir {
+		return FileState{IsDir: true}
+	}
+
+	return FileState{
+		Size:       local.size,
+		ModTime:    local.modTime.UnixNano(),
+		Revision:   remote.revision,
+		RevisionId: remote.revisionId,
+	}
+}
+
+// Checks if the path or a parent is pending
+func isPending(pending map[string]bool, rel string) bool {
+	for p := rel; p != "." && p != "/" && p != ""; p = path.Dir(p) {
+		if pending[p] {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the sorted paths of the trees and the state
+func unionPaths(local, remote map[string]entry, state *State) []string {
+	set := map[string]bool{}
+	for p := range local {
+		set[p] = true
+	}
+	for p := range remote {
+		set[p] = true
+	}
+	for p := range state.Files {
+		set[p] = true
+	}
+
+	paths := make([]string, 0, len(set))
+	for p := range set {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,399 @@
+package sync
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slok/go-copy/internal/copytest"
+)
+
+func readLocal(t *testing.T, dir, rel string) string {
+	content, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(rel)))
+	if err != nil {
+		return ""
+	}
+	return string(content)
+}
+
+func writeLocal(t *testing.T, dir, rel, content string, modTime time.Time) {
+	local := filepath.Join(dir, filepath.FromSlash(rel))
+	os.MkdirAll(filepath.Dir(local), 0755)
+	if err := ioutil.WriteFile(local, []byte(content), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+	os.Chtimes(local, modTime, modTime)
+}
+
+// Syncs a local and a remote tree for the first time
+func setupBidirectional(t *testing.T) (*copytest.Server, string) {
+	fake := copytest.NewServer(t)
+	fake.Put("work/r.txt", []byte("remote"), time.Now())
+	fake.Put("work/dir/c.txt", []byte("c"), time.Now())
+
+	local := setupLocalTree(t, map[string]string{
+		"a.txt":     "local",
+		"dir/b.txt": "b",
+	})
+
+	report, err := Bidirectional(context.Background(), fake.FS, local, "work", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := report.Err(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	fake.TakeChanges()
+	return fake, local
+}
+
+func TestBidirectionalFirstSync(t *testing.T) {
+	fake, local := setupBidirectional(t)
+	defer fake.Close()
+	defer os.RemoveAll(local)
+
+	if fake.Content("work/a.txt") != "local" || fake.Content("work/dir/b.txt") != "b" {
+		t.Errorf("Local files should be uploaded")
+	}
+
+	if readLocal(t, local, "r.txt") != "remote" || readLocal(t, local, "dir/c.txt") != "c" {
+		t.Errorf("Remote files should be downloaded")
+	}
+
+	// The state is saved and it's not synced
+	state, err := LoadState(filepath.Join(local, defaultStateFile))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(state.Files) != 5 || !state.Files["dir"].IsDir || state.Files["a.txt"].Size != 5 {
+		t.Errorf("Wrong state: %+v", state.Files)
+	}
+
+	if fake.Exists("work/" + defaultStateFile) {
+		t.Errorf("The state shouldn't be uploaded")
+	}
+
+	// Nothing changed
+	plan, err := PlanBidirectional(context.Background(), fake.FS, local, "work", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(plan.Actions) != 0 {
+		t.Errorf("Nothing should be done: %v", actionsSummary(plan))
+	}
+}
+
+func TestBidirectionalChanges(t *testing.T) {
+	fake, local := setupBidirectional(t)
+	defer fake.Close()
+	defer os.RemoveAll(local)
+
+	writeLocal(t, local, "a.txt", "local changed", newerTime)
+	os.Remove(filepath.Join(local, "dir", "b.txt"))
+	writeLocal(t, local, "new/n.txt", "n", oldTime)
+
+	fake.Put("work/r.txt", []byte("remote changed"), time.Now())
+	fake.Put("work/dir/d.txt", []byte("d"), time.Now())
+	fake.TakeChanges()
+	fake.Remove("work/dir/c.txt")
+
+	report, err := Bidirectional(context.Background(), fake.FS, local, "work", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := []string{
+		"delete dir/b.txt",
+		"delete-local dir/c.txt",
+		"mkdir new",
+		"update a.txt",
+		"download dir/d.txt",
+		"upload new/n.txt",
+		"download r.txt",
+	}
+
+	if got := actionsSummary(report.Plan); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Wrong plan:\n%v\nexpected:\n%v", got, expected)
+	}
+
+	if err := report.Err(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if fake.Content("work/a.txt") != "local changed" || readLocal(t, local, "r.txt") != "remote changed" ||
+		readLocal(t, local, "dir/d.txt") != "d" || fake.Exists("work/dir/b.txt") ||
+		readLocal(t, local, "dir/c.txt") != "" || fake.Content("work/new/n.txt") != "n" {
+		t.Errorf("Wrong sync")
+	}
+
+	// Synced
+	plan, _ := PlanBidirectional(context.Background(), fake.FS, local, "work", nil)
+	if len(plan.Actions) != 0 {
+		t.Errorf("Nothing should be done: %v", actionsSummary(plan))
+	}
+}
+
+func TestBidirectionalConcurrentChanges(t *testing.T) {
+	fake, local := setupBidirectional(t)
+	defer fake.Close()
+	defer os.RemoveAll(local)
+
+	writeLocal(t, local, "a.txt", "local changed", newerTime)
+	fake.Put("work/r.txt", []byte("remote changed"), time.Now())
+
+	// The files not synced are changed while syncing
+	changed := false
+	opts := &BidirectionalOptions{}
+	opts.OnResult = func(Result) {
+		if !changed {
+			changed = true
+			writeLocal(t, local, "dir/b.txt", "b changed", newerTime)
+			fake.Put("work/dir/c.txt", []byte("c changed"), time.Now())
+		}
+	}
+
+	report, err := Bidirectional(context.Background(), fake.FS, local, "work", opts)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := []string{"update a.txt", "download r.txt"}
+	if got := actionsSummary(report.Plan); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Wrong plan:\n%v\nexpected:\n%v", got, expected)
+	}
+
+	// The changes are synced the next time
+	plan, _ := PlanBidirectional(context.Background(), fake.FS, local, "work", nil)
+	expected = []string{"update dir/b.txt", "download dir/c.txt"}
+	if got := actionsSummary(plan); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Wrong plan:\n%v\nexpected:\n%v", got, expected)
+	}
+}
+
+func TestBidirectionalDeletedDirs(t *testing.T) {
+	fake, local := setupBidirectional(t)
+	defer fake.Close()
+	defer os.RemoveAll(local)
+
+	// Deleted locally but changed remotely, the changed file is kept
+	os.RemoveAll(filepath.Join(local, "dir"))
+	fake.Put("work/dir/c.txt", []byte("c changed"), time.Now())
+
+	plan, err := PlanBidirectional(context.Background(), fake.FS, local, "work", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := []string{"delete dir/b.txt", "mkdir-local dir", "download dir/c.txt"}
+	if got := actionsSummary(plan); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Wrong plan:\n%v\nexpected:\n%v", got, expected)
+	}
+
+	// Without remote changes the directory is deleted
+	fake.Put("work/dir/c.txt", []byte("c"), time.Now())
+	writeLocal(t, local, "dir/c.txt", "c", oldTime)
+	Bidirectional(context.Background(), fake.FS, local, "work", nil)
+	os.RemoveAll(filepath.Join(local, "dir"))
+
+	plan, err = PlanBidirectional(context.Background(), fake.FS, local, "work", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if got := actionsSummary(plan); !reflect.DeepEqual(got, []string{"delete dir"}) {
+		t.Errorf("Wrong plan: %v", got)
+	}
+}
+
+func TestBidirectionalExcludedRemoteFile(t *testing.T) {
+	fake, local := setupBidirectional(t)
+	defer fake.Close()
+	defer os.RemoveAll(local)
+
+	// An excluded remote file before the rest of the entries
+	fake.Put("work/0.tmp", []byte("temp"), time.Now())
+	opts := &BidirectionalOptions{
+		Exclude: func(path string, isDir bool) bool {
+			return filepath.Ext(path) == ".tmp"
+		},
+	}
+
+	plan, err := PlanBidirectional(context.Background(), fake.FS, local, "work", opts)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if got := actionsSummary(plan); len(got) != 0 {
+		t.Errorf("Nothing should change: %v", got)
+	}
+
+	report, err := Bidirectional(context.Background(), fake.FS, local, "work", opts)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(report.Results) != 0 || readLocal(t, local, "a.txt") != "local" || readLocal(t, local, "r.txt") != "remote" ||
+		readLocal(t, local, "dir/b.txt") != "b" {
+		t.Errorf("The local files shouldn't change: %+v", report.Results)
+	}
+}
+
+func TestCheckRemoteDeleted(t *testing.T) {
+	fake, local := setupBidirectional(t)
+	defer fake.Close()
+	defer os.RemoveAll(local)
+
+	state, err := LoadState(filepath.Join(local, defaultStateFile))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// A listing without r.txt and dir
+	remote := map[string]entry{"a.txt": {}}
+	err = checkRemoteDeleted(context.Background(), fake.FS, "work", remote, state, nil)
+	if err == nil || !strings.Contains(err.Error(), "dir exists") {
+		t.Errorf("Should be an incomplete listing error: %v", err)
+	}
+
+	// Really deleted
+	remote = map[string]entry{"a.txt": {}, "dir": {isDir: true}, "dir/b.txt": {}, "dir/c.txt": {}}
+	state.Files["gone/x.txt"] = FileState{}
+	state.Files["gone"] = FileState{IsDir: true}
+	if err := checkRemoteDeleted(context.Background(), fake.FS, "work", remote, state, func(p string, isDir bool) bool {
+		return p == "r.txt"
+	}); err != nil {
+		t.Errorf("Shouldn't be an error: %v", err)
+	}
+}
+
+func TestBidirectionalConflicts(t *testing.T) {
+	fake, local := setupBidirectional(t)
+	defer fake.Close()
+	defer os.RemoveAll(local)
+
+	writeLocal(t, local, "a.txt", "local changed", newerTime)
+	fake.Put("work/a.txt", []byte("remote changed"), time.Now())
+
+	tests := []struct {
+		strategy ConflictStrategy
+		expected []string
+	}{
+		{KeepLocal, []string{"update a.txt"}},
+		{KeepRemote, []string{"download a.txt"}},
+		{Skip, []string{}},
+		{KeepBoth, []string{"rename-local a.conflict.txt", "upload a.conflict.txt", "download a.txt"}},
+	}
+
+	for _, test := range tests {
+		plan, err := PlanBidirectional(context.Background(), fake.FS, local, "work", &BidirectionalOptions{Strategy: test.strategy})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if got := actionsSummary(plan); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("Wrong plan with %s: %v", test.strategy, got)
+		}
+
+		if len(plan.Conflicts) != 1 || plan.Conflicts[0].Path != "a.txt" || plan.Conflicts[0].Resolution != test.strategy {
+			t.Errorf("Wrong conflicts with %s: %+v", test.strategy, plan.Conflicts)
+		}
+	}
+
+	// The callback decides
+	var conflict Conflict
+	opts := &BidirectionalOptions{
+		Strategy: KeepRemote,
+		OnConflict: func(c Conflict) ConflictStrategy {
+			conflict = c
+			return KeepBoth
+		},
+	}
+
+	report, err := Bidirectional(context.Background(), fake.FS, local, "work", opts)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := report.Err(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if conflict.Path != "a.txt" || conflict.LocalDeleted || conflict.RemoteDeleted {
+		t.Errorf("Wrong conflict: %+v", conflict)
+	}
+
+	if readLocal(t, local, "a.txt") != "remote changed" || readLocal(t, local, "a.conflict.txt") != "local changed" ||
+		fake.Content("work/a.txt") != "remote changed" || fake.Content("work/a.conflict.txt") != "local changed" {
+		t.Errorf("Both versions should be kept")
+	}
+
+	plan, _ := PlanBidirectional(context.Background(), fake.FS, local, "work", nil)
+	if len(plan.Actions) != 0 || len(plan.Conflicts) != 0 {
+		t.Errorf("Nothing should be done: %v", actionsSummary(plan))
+	}
+}
+
+func TestBidirectionalSkippedConflictsArePending(t *testing.T) {
+	fake, local := setupBidirectional(t)
+	defer fake.Close()
+	defer os.RemoveAll(local)
+
+	writeLocal(t, local, "a.txt", "local changed", newerTime)
+	fake.Put("work/a.txt", []byte("remote changed"), time.Now())
+
+	if _, err := Bidirectional(context.Background(), fake.FS, local, "work", &BidirectionalOptions{Strategy: Skip}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// Still a conflict
+	plan, _ := PlanBidirectional(context.Background(), fake.FS, local, "work", nil)
+	if len(plan.Conflicts) != 1 {
+		t.Errorf("The conflict should be pending: %+v", plan.Conflicts)
+	}
+
+	// Dry runs don't save the state
+	opts := &BidirectionalOptions{Strategy: KeepLocal}
+	opts.DryRun = true
+	Bidirectional(context.Background(), fake.FS, local, "work", opts)
+
+	plan, _ = PlanBidirectional(context.Background(), fake.FS, local, "work", nil)
+	if len(plan.Conflicts) != 1 {
+		t.Errorf("The conflict should be pending: %+v", plan.Conflicts)
+	}
+}
+
+func TestStateFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "go-copy-state")
+	defer os.RemoveAll(dir)
+	statePath := filepath.Join(dir, "state.json")
+
+	// Missing file is an empty state
+	state, err := LoadState(statePath)
+	if err != nil || len(state.Files) != 0 {
+		t.Fatalf("Wrong empty state: %v, %v", state, err)
+	}
+
+	state.Files["a.txt"] = FileState{Size: 1, ModTime: 2, Revision: 3, RevisionId: 4}
+	if err := state.Save(statePath); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	loaded, err := LoadState(statePath)
+	if err != nil || !reflect.DeepEqual(loaded, state) {
+		t.Errorf("Wrong loaded state: %+v, %v", loaded, err)
+	}
+
+	ioutil.WriteFile(statePath, []byte(`{"version": 99}`), 0644)
+	if _, err := LoadState(statePath); err == nil {
+		t.Errorf("Unknown versions should be an error")
+	}
+}
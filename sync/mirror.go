@@ -0,0 +1,392 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"github.com/slok/go-copy/copy"
+)
+
+// Execution options of the plans
+type ExecuteOptions struct {
+	// Number of actions executed at the same time
+	Concurrency int
+
+	// Don't change anything, only report what would be done
+	DryRun bool
+
+	// Called after every action (from many goroutines at the same time)
+	OnResult func(Result)
+}
+
+// Mirror options
+type MirrorOptions struct {
+	ExecuteOptions
+
+	// Don't delete the remote files that don't exist locally
+	NoDelete bool
+
+	// Skips the paths (relative to the synced directories, with slashes) on
+	// both sides. The skipped directories are not walked
+	Exclude func(path string, isDir bool) bool
+}
+
+const defaultConcurrency = 4
+
+// A file or directory of a tree
+type entry struct {
+	isDir   bool
+	size    int64
+	modTime time.Time
+
+	// Only remote entries
+	revision   int
+	revisionId int
+}
+
+// Makes the remote directory equal to the local directory: uploads the new
+// files, updates the changed ones (different size or modified locally after
+// the remote one), creates the missing directories and deletes the remote
+// files that don't exist locally
+func Mirror(ctx context.Context, fs *copy.FileService, localDir, remoteDir string, opts *MirrorOptions) (*Report, error) {
+	if opts == nil {
+		opts = &MirrorOptions{}
+	}
+
+	plan, err := PlanMirror(ctx, fs, localDir, remoteDir, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return plan.Execute(ctx, fs, &opts.ExecuteOptions), nil
+}
+
+// Compares the local and the remote directories and returns the actions
+// that Mirror would execute
+func PlanMirror(ctx context.Context, fs *copy.FileService, localDir, remoteDir string, opts *MirrorOptions) (*Plan, error) {
+	if opts == nil {
+		opts = &MirrorOptions{}
+	}
+
+	remoteDir = strings.Trim(remoteDir, "/")
+
+	local, err := localTree(localDir, opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	remote, rootExists, err := remoteTree(ctx, fs, remoteDir, opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{
+		LocalDir:  localDir,
+		RemoteDir: remoteDir,
+	}
+
+	newAction := func(t ActionType, rel string, size int64) Action {
+		return Action{
+			Type:       t,
+			Path:       rel,
+			LocalPath:  filepath.Join(localDir, filepath.FromSlash(rel)),
+			RemotePath: remotePath(remoteDir, rel),
+			Size:       size,
+		}
+	}
+
+	deletes := []Action{}
+	mkdirs := []Action{}
+	uploads := []Action{}
+
+	if !rootExists {
+		mkdirs = append(mkdirs, newAction(Mkdir, "", 0))
+	}
+
+	for _, rel := range sortedPaths(local) {
+		l := local[rel]
+		r, ok := remote[rel]
+
+		// A file replaces a directory or the opposite
+		if ok && r.isDir != l.isDir {
+			deletes = append(deletes, newAction(Delete, rel, 0))
+			deleteSubtree(remote, rel)
+			ok = false
+		}
+
+		switch {
+		case !ok && l.isDir:
+			mkdirs = append(mkdirs, newAction(Mkdir, rel, 0))
+		case !ok:
+			uploads = append(uploads, newAction(Upload, rel, l.size))
+		case !l.isDir && changed(l, r):
+			uploads = append(uploads, newAction(Update, rel, l.size))
+		}
+	}
+
+	if !opts.NoDelete {
+		for _, rel := range sortedPaths(remote) {
+			if _, ok := local[rel]; ok {
+				continue
+			}
+
+			// Deleting the directory deletes its content
+			if _, ok := remote[rel]; ok {
+				deletes = append(deletes, newAction(Delete, rel, 0))
+				deleteSubtree(remote, rel)
+			}
+		}
+	}
+
+	plan.Actions = append(plan.Actions, deletes...)
+	plan.Actions = append(plan.Actions, mkdirs...)
+	plan.Actions = append(plan.Actions, uploads...)
+
+	return plan, nil
+}
+
+// Executes the actions of the plan: first the deletes and the renames, then
+// the directories are created (in order) and finally the files are uploaded
+// and downloaded. A failed action doesn't stop the execution, check the
+// report
+func (p *Plan) Execute(ctx context.Context, fs *copy.FileService, opts *ExecuteOptions) *Report {
+	if opts == nil {
+		opts = &ExecuteOptions{}
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+
+	start := time.Now()
+	report := &Report{
+		Plan:    p,
+		Results: make([]Result, len(p.Actions)),
+		DryRun:  opts.DryRun,
+	}
+
+	// The paths of the failed renames, the file wasn't moved so it can't be
+	// replaced
+	var renameFailed gosync.Map
+
+	execute := func(i int) {
+		action := p.Actions[i]
+		var err error
+
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		} else if _, failed := renameFailed.Load(action.Path); failed && action.Type == Download {
+			err = errors.New("Not downloaded, the local file couldn't be renamed")
+		} else if !opts.DryRun {
+			err = executeAction(ctx, fs, action)
+		}
+
+		if err != nil && action.Type == LocalRename {
+			renameFailed.Store(action.OldPath, true)
+		}
+
+		report.Results[i] = Result{Action: action, Err: err}
+		if opts.OnResult != nil {
+			opts.OnResult(report.Results[i])
+		}
+	}
+
+	// The phases of the plan, the parents need to be created before the
+	// children so the directories are created one by one
+	phases := [][]int{{}, {}, {}, {}}
+	for i, a := range p.Actions {
+		switch a.Type {
+		case Delete, LocalDelete:
+			phases[0] = append(phases[0], i)
+		case LocalRename:
+			phases[1] = append(phases[1], i)
+		case Mkdir, LocalMkdir:
+			phases[2] = append(phases[2], i)
+		default:
+			phases[3] = append(phases[3], i)
+		}
+	}
+
+	runConcurrently(phases[0], concurrency, execute)
+	runConcurrently(phases[1], concurrency, execute)
+	runConcurrently(phases[2], 1, execute)
+	runConcurrently(phases[3], concurrency, execute)
+
+	report.Duration = time.Since(start)
+	return report
+}
+
+// Executes the action in Copy
+func executeAction(ctx context.Context, fs *copy.FileService, a Action) error {
+	switch a.Type {
+	case Mkdir:
+		return fs.CreateDirectoryContext(ctx, a.RemotePath, false)
+	case Upload:
+		return fs.UploadFileWithOptions(ctx, a.LocalPath, a.RemotePath, &copy.UploadOptions{Overwrite: true})
+	case Update:
+		return fs.UpdateFileWithOptions(ctx, a.LocalPath, a.RemotePath, nil)
+	case Delete:
+		return fs.DeleteFileContext(ctx, a.RemotePath)
+	case Download:
+		return fs.DownloadToFile(ctx, a.RemotePath, a.LocalPath, &copy.DownloadOptions{NoResume: true})
+	case LocalMkdir:
+		return os.MkdirAll(a.LocalPath, 0755)
+	case LocalDelete:
+		return os.RemoveAll(a.LocalPath)
+	case LocalRename:
+		oldLocalPath := strings.TrimSuffix(a.LocalPath, filepath.FromSlash(a.Path)) + filepath.FromSlash(a.OldPath)
+		return os.Rename(oldLocalPath, a.LocalPath)
+	}
+	return nil
+}
+
+// Calls fn with every index from concurrency goroutines
+func runConcurrently(indexes []int, concurrency int, fn func(i int)) {
+	work := make(chan int)
+	var wg gosync.WaitGroup
+
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				fn(i)
+			}
+		}()
+	}
+
+	for _, i := range indexes {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+}
+
+// Checks if the local file needs to be uploaded again, Copy sets the
+// modified time when the file is uploaded so only newer local files changed
+func changed(local, remote entry) bool {
+	return local.size != remote.size || local.modTime.Truncate(time.Second).After(remote.modTime)
+}
+
+// Returns the files and directories of the local directory (the root not
+// included) by relative path
+func localTree(localDir string, exclude func(string, bool) bool) (map[string]entry, error) {
+	tree := map[string]entry{}
+
+	err := filepath.Walk(localDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(localDir, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		if exclude != nil && exclude(rel, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// Symlinks, devices...
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+
+		tree[rel] = localEntry(info)
+		return nil
+	})
+
+	return tree, err
+}
+
+func localEntry(info os.FileInfo) entry {
+	return entry{
+		isDir:   info.IsDir(),
+		size:    info.Size(),
+		modTime: info.ModTime(),
+	}
+}
+
+// Returns the files and directories of the remote directory (the root not
+// included) by relative path, if the directory doesn't exist the tree is
+// empty
+func remoteTree(ctx context.Context, fs *copy.FileService, remoteDir string, exclude func(string, bool) bool) (map[string]entry, bool, error) {
+	tree := map[string]entry{}
+	rootExists := true
+	root := "/" + remoteDir
+
+	err := fs.Walk(ctx, root, func(p string, meta *copy.Meta, err error) error {
+		if err != nil {
+			if p == root && copy.IsNotFound(err) {
+				rootExists = false
+				return copy.SkipDir
+			}
+			return err
+		}
+
+		rel := strings.TrimPrefix(strings.TrimPrefix(p, root), "/")
+		if rel == "" {
+			return nil
+		}
+
+		if exclude != nil && exclude(rel, meta.IsDir()) {
+			if meta.IsDir() {
+				return copy.SkipDir
+			}
+			return nil
+		}
+
+		tree[rel] = remoteEntry(meta)
+		return nil
+	})
+
+	return tree, rootExists, err
+}
+
+func remoteEntry(meta *copy.Meta) entry {
+	return entry{
+		isDir:      meta.IsDir(),
+		size:       int64(meta.Size),
+		modTime:    meta.ModifiedTime.Time,
+		revision:   meta.Revision,
+		revisionId: meta.RevisionId,
+	}
+}
+
+// Removes the path and its children from the tree
+func deleteSubtree(tree map[string]entry, rel string) {
+	for p := range tree {
+		if p == rel || strings.HasPrefix(p, rel+"/") {
+			delete(tree, p)
+		}
+	}
+}
+
+func sortedPaths(tree map[string]entry) []string {
+	paths := make([]string, 0, len(tree))
+	for p := range tree {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Returns the Copy path of the relative path
+func remotePath(remoteDir, rel string) string {
+	return strings.Trim(path.Join(remoteDir, rel), "/")
+}
@@ -0,0 +1,297 @@
+package sync
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	gosync "sync"
+	"testing"
+	"time"
+
+	"github.com/slok/go-copy/internal/copytest"
+)
+
+var (
+	oldTime   = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	newerTime = time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// Creates a local tree, the files have old modified times (older than the
+// remote ones) unless the name starts with "newer" (newer than the remote
+// ones set by setupMirror). The paths ending with / are directories
+func setupLocalTree(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "go-copy-sync")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for p, content := range files {
+		local := filepath.Join(dir, filepath.FromSlash(p))
+		if strings.HasSuffix(p, "/") {
+			os.MkdirAll(local, 0755)
+			continue
+		}
+
+		os.MkdirAll(filepath.Dir(local), 0755)
+		if err := ioutil.WriteFile(local, []byte(content), 0644); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		modTime := oldTime
+		if strings.HasPrefix(filepath.Base(p), "newer") {
+			modTime = newerTime
+		}
+		os.Chtimes(local, modTime, modTime)
+	}
+
+	return dir
+}
+
+func actionsSummary(plan *Plan) []string {
+	summary := []string{}
+	for _, a := range plan.Actions {
+		summary = append(summary, a.Type.String()+" "+a.Path)
+	}
+	return summary
+}
+
+func setupMirror(t *testing.T) (*copytest.Server, string) {
+	fake := copytest.NewServer(t)
+
+	now := time.Now()
+	fake.Put("backup/same.txt", []byte("same"), now)
+	fake.Put("backup/changed.txt", []byte("previous content"), now)
+	fake.Put("backup/newer.txt", []byte("1234"), newerTime.Add(-time.Hour))
+	fake.Put("backup/old.txt", []byte("old"), now)
+	fake.Put("backup/gone/x.txt", []byte("x"), now)
+	fake.Put("backup/conflict", []byte("a file"), now)
+
+	local := setupLocalTree(t, map[string]string{
+		"a.txt":             "new file",
+		"same.txt":          "same",
+		"changed.txt":       "new content",
+		"newer.txt":         "abcd",
+		"docs/b.txt":        "b",
+		"docs/empty/":       "",
+		"conflict/file.txt": "now a dir",
+	})
+
+	return fake, local
+}
+
+func TestPlanMirror(t *testing.T) {
+	fake, local := setupMirror(t)
+	defer fake.Close()
+	defer os.RemoveAll(local)
+
+	plan, err := PlanMirror(context.Background(), fake.FS, local, "/backup/", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := []string{
+		"delete conflict",
+		"delete gone",
+		"delete old.txt",
+		"mkdir conflict",
+		"mkdir docs",
+		"mkdir docs/empty",
+		"upload a.txt",
+		"update changed.txt",
+		"upload conflict/file.txt",
+		"upload docs/b.txt",
+		"update newer.txt",
+	}
+
+	if got := actionsSummary(plan); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Wrong plan:\n%v\nexpected:\n%v", got, expected)
+	}
+
+	a := plan.Actions[len(plan.Actions)-2]
+	if a.RemotePath != "backup/docs/b.txt" || a.LocalPath != filepath.Join(local, "docs", "b.txt") || a.Size != 1 {
+		t.Errorf("Wrong action: %+v", a)
+	}
+
+	// Without deletes and excluding the docs
+	opts := &MirrorOptions{
+		NoDelete: true,
+		Exclude: func(path string, isDir bool) bool {
+			return path == "docs"
+		},
+	}
+	plan, err = PlanMirror(context.Background(), fake.FS, local, "backup", opts)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected = []string{
+		"delete conflict",
+		"mkdir conflict",
+		"upload a.txt",
+		"update changed.txt",
+		"upload conflict/file.txt",
+		"update newer.txt",
+	}
+
+	if got := actionsSummary(plan); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Wrong plan:\n%v\nexpected:\n%v", got, expected)
+	}
+}
+
+func TestPlanMirrorExcludedRemoteFile(t *testing.T) {
+	fake := copytest.NewServer(t)
+	defer fake.Close()
+
+	now := time.Now()
+	fake.Put("backup/0.tmp", []byte("temp"), now)
+	fake.Put("backup/same.txt", []byte("same"), now)
+	fake.Put("backup/old.txt", []byte("old"), now)
+
+	local := setupLocalTree(t, map[string]string{
+		"same.txt": "same",
+	})
+	defer os.RemoveAll(local)
+
+	// The excluded file doesn't hide the rest of the directory
+	opts := &MirrorOptions{
+		Exclude: func(path string, isDir bool) bool {
+			return strings.HasSuffix(path, ".tmp")
+		},
+	}
+	plan, err := PlanMirror(context.Background(), fake.FS, local, "backup", opts)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := []string{"delete old.txt"}
+	if got := actionsSummary(plan); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Wrong plan:\n%v\nexpected:\n%v", got, expected)
+	}
+}
+
+func TestMirror(t *testing.T) {
+	fake, local := setupMirror(t)
+	defer fake.Close()
+	defer os.RemoveAll(local)
+
+	var mu gosync.Mutex
+	results := 0
+	opts := &MirrorOptions{
+		ExecuteOptions: ExecuteOptions{
+			Concurrency: 3,
+			OnResult: func(Result) {
+				mu.Lock()
+				results++
+				mu.Unlock()
+			},
+		},
+	}
+
+	report, err := Mirror(context.Background(), fake.FS, local, "backup", opts)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := report.Err(); err != nil {
+		t.Fatalf("Shouldn't be an error: %v", err)
+	}
+
+	if results != 11 || report.Count(Upload) != 3 || report.Count(Update) != 2 ||
+		report.Count(Delete) != 3 || report.Count(Mkdir) != 3 {
+		t.Errorf("Wrong report: %s", report)
+	}
+
+	if report.BytesUploaded() != int64(len("new file")+len("new content")+len("now a dir")+len("b")+len("abcd")) {
+		t.Errorf("Wrong uploaded bytes: %d", report.BytesUploaded())
+	}
+
+	contents := map[string]string{
+		"backup/a.txt":             "new file",
+		"backup/same.txt":          "same",
+		"backup/changed.txt":       "new content",
+		"backup/newer.txt":         "abcd",
+		"backup/docs/b.txt":        "b",
+		"backup/conflict/file.txt": "now a dir",
+	}
+	for p, content := range contents {
+		if got := fake.Content(p); got != content {
+			t.Errorf("Wrong content of %s: %q", p, got)
+		}
+	}
+
+	for _, p := range []string{"backup/old.txt", "backup/gone", "backup/gone/x.txt"} {
+		if fake.Exists(p) {
+			t.Errorf("%s should be deleted", p)
+		}
+	}
+
+	if !fake.Exists("backup/docs/empty") {
+		t.Errorf("Empty dir should be created")
+	}
+
+	// Everything is synced now
+	plan, err := PlanMirror(context.Background(), fake.FS, local, "backup", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(plan.Actions) != 0 {
+		t.Errorf("Nothing should be done: %v", actionsSummary(plan))
+	}
+}
+
+func TestMirrorDryRun(t *testing.T) {
+	fake, local := setupMirror(t)
+	defer fake.Close()
+	defer os.RemoveAll(local)
+
+	fake.TakeChanges()
+
+	opts := &MirrorOptions{ExecuteOptions: ExecuteOptions{DryRun: true}}
+	report, err := Mirror(context.Background(), fake.FS, local, "backup", opts)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if changes := fake.TakeChanges(); len(changes) != 0 {
+		t.Errorf("Dry run shouldn't change anything: %v", changes)
+	}
+
+	if report.Count(Upload) != 3 || !strings.HasPrefix(report.String(), "(dry run) 3 uploaded, 2 updated, 3 deleted, 3 dirs created, 0 failed") {
+		t.Errorf("Wrong report: %s", report)
+	}
+}
+
+func TestMirrorNewRemoteDir(t *testing.T) {
+	fake := copytest.NewServer(t)
+	defer fake.Close()
+
+	local := setupLocalTree(t, map[string]string{"a.txt": "a"})
+	defer os.RemoveAll(local)
+
+	report, err := Mirror(context.Background(), fake.FS, local, "new/backup", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if got := actionsSummary(report.Plan); !reflect.DeepEqual(got, []string{"mkdir ", "upload a.txt"}) {
+		t.Errorf("Wrong plan: %v", got)
+	}
+
+	if err := report.Err(); err != nil {
+		t.Errorf("Shouldn't be an error: %v", err)
+	}
+
+	expected := []string{"POST new/backup", "POST new/backup/a.txt"}
+	if changes := fake.TakeChanges(); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Wrong changes: %v", changes)
+	}
+
+	// Missing local dir
+	if _, err := Mirror(context.Background(), fake.FS, filepath.Join(local, "nope"), "new/backup", nil); err == nil {
+		t.Errorf("Should be an error")
+	}
+}
@@ -0,0 +1,96 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Name of the state file inside the local directory (by default)
+const defaultStateFile = ".copy-sync.json"
+
+// Version of the state file format
+const stateVersion = 1
+
+// State is the state of the files after the last two way sync, it is used
+// for detecting the changes of each side
+type State struct {
+	Version int                  `json:"version"`
+	Files   map[string]FileState `json:"files"`
+}
+
+// FileState is the state of a file (or directory) after the last sync
+type FileState struct {
+	IsDir bool `json:"is_dir,omitempty"`
+
+	// Local size and modified time (unix nanoseconds)
+	Size    int64 `json:"size,omitempty"`
+	ModTime int64 `json:"mod_time,omitempty"`
+
+	// Remote revision
+	Revision   int `json:"revision,omitempty"`
+	RevisionId int `json:"revision_id,omitempty"`
+}
+
+// Creates an empty state
+func NewState() *State {
+	return &State{
+		Version: stateVersion,
+		Files:   map[string]FileState{},
+	}
+}
+
+// Loads the state from the file, if the file doesn't exist the state is
+// empty (nothing was synced)
+func LoadState(path string) (*State, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return NewState(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state := NewState()
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("Wrong sync state file %s: %v", path, err)
+	}
+
+	if state.Version != stateVersion {
+		return nil, fmt.Errorf("Wrong sync state file %s: unknown version %d", path, state.Version)
+	}
+
+	if state.Files == nil {
+		state.Files = map[string]FileState{}
+	}
+
+	return state, nil
+}
+
+// Saves the state in the file, the file is replaced atomically
+func (s *State) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
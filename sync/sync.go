@@ -0,0 +1,211 @@
+// Package sync synchronizes local directories with Copy folders.
+//
+// Mirror makes a Copy folder equal to a local directory (one way), the
+// changes are computed first as a Plan that can be reviewed (or only
+// printed with a dry run) before executing it:
+//
+//	plan, err := sync.PlanMirror(ctx, fs, "/home/slok/photos", "backups/photos", nil)
+//	report := plan.Execute(ctx, fs, nil)
+//	fmt.Println(report)
+//
+// Bidirectional syncs the changes of both sides, the state of the last sync
+// is saved in a local file for detecting what changed on each side and the
+// conflicts (changed on both sides)
+package sync
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The type of the change that an action makes
+type ActionType int
+
+const (
+	// Creates a remote directory
+	Mkdir ActionType = iota
+	// Uploads a local file that doesn't exist in Copy
+	Upload
+	// Uploads a local file that changed over the remote one
+	Update
+	// Deletes a remote file or directory
+	Delete
+	// Downloads a remote file that doesn't exist locally or changed
+	Download
+	// Creates a local directory
+	LocalMkdir
+	// Deletes a local file or directory
+	LocalDelete
+	// Renames a local file (OldPath to Path), for keeping both versions of
+	// a conflict
+	LocalRename
+)
+
+func (t ActionType) String() string {
+	switch t {
+	case Mkdir:
+		return "mkdir"
+	case Upload:
+		return "upload"
+	case Update:
+		return "update"
+	case Delete:
+		return "delete"
+	case Download:
+		return "download"
+	case LocalMkdir:
+		return "mkdir-local"
+	case LocalDelete:
+		return "delete-local"
+	case LocalRename:
+		return "rename-local"
+	}
+	return fmt.Sprintf("ActionType(%d)", int(t))
+}
+
+// Action is a change of the plan
+type Action struct {
+	Type ActionType
+
+	// Path relative to the synced directories, with slashes
+	Path string
+
+	LocalPath  string
+	RemotePath string
+
+	// Relative path of the renamed file (only renames)
+	OldPath string
+
+	// Bytes that will be uploaded or downloaded
+	Size int64
+}
+
+func (a Action) String() string {
+	if a.Type == LocalRename {
+		return fmt.Sprintf("%-12s %s -> %s", a.Type, a.OldPath, a.Path)
+	}
+	return fmt.Sprintf("%-12s %s", a.Type, a.Path)
+}
+
+// Plan has the actions for synchronizing the directories, in execution
+// order: deletes, renames, directory creations and transfers
+type Plan struct {
+	LocalDir  string
+	RemoteDir string
+
+	Actions []Action
+
+	// The conflicts found by the two way sync and how they were resolved
+	Conflicts []Conflict
+}
+
+// Returns the number of actions of the type
+func (p *Plan) Count(t ActionType) int {
+	n := 0
+	for _, a := range p.Actions {
+		if a.Type == t {
+			n++
+		}
+	}
+	return n
+}
+
+func (p *Plan) String() string {
+	lines := []string{}
+	for _, a := range p.Actions {
+		lines = append(lines, a.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Result is the result of executing an action
+type Result struct {
+	Action Action
+	Err    error
+}
+
+// Report is the result of executing a plan
+type Report struct {
+	Plan    *Plan
+	Results []Result
+	DryRun  bool
+
+	Duration time.Duration
+}
+
+// Returns the results that failed
+func (r *Report) Failed() []Result {
+	failed := []Result{}
+	for _, res := range r.Results {
+		if res.Err != nil {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// Returns an error with all the failed actions, nil if everything was ok
+func (r *Report) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+
+	msgs := []string{}
+	for _, res := range failed {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", res.Action, res.Err))
+	}
+
+	return fmt.Errorf("%d sync actions failed: %s", len(failed), strings.Join(msgs, "; "))
+}
+
+// Returns the number of successful actions of the type
+func (r *Report) Count(t ActionType) int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Err == nil && res.Action.Type == t {
+			n++
+		}
+	}
+	return n
+}
+
+// Returns the bytes uploaded by the successful actions
+func (r *Report) BytesUploaded() int64 {
+	return r.bytes(Upload, Update)
+}
+
+// Returns the bytes downloaded by the successful actions
+func (r *Report) BytesDownloaded() int64 {
+	return r.bytes(Download)
+}
+
+func (r *Report) bytes(types ...ActionType) int64 {
+	var n int64
+	for _, res := range r.Results {
+		for _, t := range types {
+			if res.Err == nil && res.Action.Type == t {
+				n += res.Action.Size
+			}
+		}
+	}
+	return n
+}
+
+func (r *Report) String() string {
+	prefix := ""
+	if r.DryRun {
+		prefix = "(dry run) "
+	}
+
+	local := ""
+	if r.Count(Download)+r.Count(LocalMkdir)+r.Count(LocalDelete)+r.Count(LocalRename) > 0 || len(r.Plan.Conflicts) > 0 {
+		local = fmt.Sprintf(", %d downloaded, %d deleted locally, %d local dirs created, %d conflicts",
+			r.Count(Download), r.Count(LocalDelete), r.Count(LocalMkdir), len(r.Plan.Conflicts))
+	}
+
+	return fmt.Sprintf("%s%d uploaded, %d updated, %d deleted, %d dirs created%s, %d failed, %d bytes in %s",
+		prefix, r.Count(Upload), r.Count(Update), r.Count(Delete), r.Count(Mkdir), local,
+		len(r.Failed()), r.BytesUploaded()+r.BytesDownloaded(), r.Duration.Round(time.Millisecond))
+}